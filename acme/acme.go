@@ -0,0 +1,230 @@
+// Package acme obtains certificates from an ACME CA like Let's Encrypt with
+// an embedded lego client.
+package acme
+
+import (
+	"crypto"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-acme/lego/v4/certcrypto"
+	"github.com/go-acme/lego/v4/certificate"
+	"github.com/go-acme/lego/v4/challenge/http01"
+	"github.com/go-acme/lego/v4/lego"
+	"github.com/go-acme/lego/v4/providers/dns"
+	"github.com/go-acme/lego/v4/providers/http/webroot"
+	"github.com/go-acme/lego/v4/registration"
+	log "github.com/sirupsen/logrus"
+)
+
+// AccountFile is the name of the file in Options.Dir holding the account
+// key and registration.
+const AccountFile = "account.json"
+
+var keyTypes = map[string]certcrypto.KeyType{
+	"ec256":   certcrypto.EC256,
+	"ec384":   certcrypto.EC384,
+	"rsa2048": certcrypto.RSA2048,
+	"rsa4096": certcrypto.RSA4096,
+}
+
+// Options configure the account and how challenges are solved.
+type Options struct {
+	// Dir holds the account file.
+	Dir   string
+	Email string
+	// CAServer is the directory URL of the CA, Let's Encrypt if empty.
+	CAServer string
+	// KeyType of the certificate keys: ec256, ec384, rsa2048 or rsa4096.
+	// Defaults to ec256.
+	KeyType string
+	// AcceptTOS must be set to register an account.
+	AcceptTOS bool
+
+	// DNSProvider selects DNS-01 with a lego DNS provider configured by its
+	// environment variables. Otherwise HTTP-01 is used, writing the
+	// challenges to Webroot if set or serving them on HTTPAddr.
+	DNSProvider string
+	Webroot     string
+	HTTPAddr    string
+}
+
+type account struct {
+	Email        string                 `json:"email"`
+	Registration *registration.Resource `json:"registration"`
+	// Key is the DER encoded PKCS#8 account key. It is not stored as PEM
+	// so the scanner does not report it as an orphaned key.
+	Key []byte `json:"key"`
+
+	key crypto.PrivateKey
+}
+
+func (a *account) GetEmail() string                        { return a.Email }
+func (a *account) GetRegistration() *registration.Resource { return a.Registration }
+func (a *account) GetPrivateKey() crypto.PrivateKey        { return a.key }
+
+// Client obtains certificates with a registered account.
+type Client struct {
+	lego *lego.Client
+}
+
+// loadAccount reads the account file, creating a new key if it does not
+// exist yet.
+func loadAccount(path string, email string) (*account, error) {
+	acc := &account{}
+
+	content, err := ioutil.ReadFile(path)
+	if err == nil {
+		err = json.Unmarshal(content, acc)
+		if err != nil {
+			return nil, errors.New(path + ": " + err.Error())
+		}
+
+		acc.key, err = x509.ParsePKCS8PrivateKey(acc.Key)
+		if err != nil {
+			return nil, errors.New(path + ": " + err.Error())
+		}
+
+		if email != "" && acc.Email != email {
+			log.WithFields(log.Fields{"path": path, "email": acc.Email}).Warn("ACME account was registered with another email address")
+		}
+
+		return acc, nil
+	}
+
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	acc.Email = email
+
+	acc.key, err = certcrypto.GeneratePrivateKey(certcrypto.EC256)
+	if err != nil {
+		return nil, err
+	}
+
+	acc.Key, err = x509.MarshalPKCS8PrivateKey(acc.key)
+	if err != nil {
+		return nil, err
+	}
+
+	return acc, nil
+}
+
+func saveAccount(path string, acc *account) error {
+	content, err := json.MarshalIndent(acc, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, content, 0600)
+}
+
+// NewClient loads or registers the account and sets up the challenge
+// solver.
+func NewClient(opts *Options) (*Client, error) {
+	keyType := certcrypto.EC256
+	if opts.KeyType != "" {
+		var ok bool
+
+		keyType, ok = keyTypes[strings.ToLower(opts.KeyType)]
+		if !ok {
+			return nil, errors.New("unknown ACME key type " + opts.KeyType)
+		}
+	}
+
+	path := filepath.Join(opts.Dir, AccountFile)
+
+	acc, err := loadAccount(path, opts.Email)
+	if err != nil {
+		return nil, err
+	}
+
+	config := lego.NewConfig(acc)
+	config.Certificate.KeyType = keyType
+
+	if opts.CAServer != "" {
+		config.CADirURL = opts.CAServer
+	}
+
+	client, err := lego.NewClient(config)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case opts.DNSProvider != "":
+		provider, err := dns.NewDNSChallengeProviderByName(opts.DNSProvider)
+		if err != nil {
+			return nil, err
+		}
+
+		err = client.Challenge.SetDNS01Provider(provider)
+		if err != nil {
+			return nil, err
+		}
+	case opts.Webroot != "":
+		provider, err := webroot.NewHTTPProvider(opts.Webroot)
+		if err != nil {
+			return nil, err
+		}
+
+		err = client.Challenge.SetHTTP01Provider(provider)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		host, port, err := net.SplitHostPort(opts.HTTPAddr)
+		if err != nil {
+			return nil, err
+		}
+
+		err = client.Challenge.SetHTTP01Provider(http01.NewProviderServer(host, port))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if acc.Registration == nil {
+		if !opts.AcceptTOS {
+			return nil, errors.New("the terms of service of the CA must be accepted to register an ACME account: " + client.GetToSURL())
+		}
+
+		acc.Registration, err = client.Registration.Register(registration.RegisterOptions{TermsOfServiceAgreed: true})
+		if err != nil {
+			return nil, err
+		}
+
+		log.WithFields(log.Fields{"email": acc.Email, "account": acc.Registration.URI}).Info("Registered ACME account")
+
+		err = saveAccount(path, acc)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &Client{lego: client}, nil
+}
+
+// Obtain requests a certificate for domains, the first one being the
+// subject. It returns the certificate with its chain and the private key,
+// both PEM encoded.
+func (c *Client) Obtain(domains []string) ([]byte, []byte, error) {
+	resource, err := c.lego.Certificate.Obtain(certificate.ObtainRequest{Domains: domains, Bundle: true})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return resource.Certificate, resource.PrivateKey, nil
+}
@@ -0,0 +1,238 @@
+package aws
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Options configure the region and credentials of a Client.
+type Options struct {
+	// Region defaults to $AWS_REGION or $AWS_DEFAULT_REGION.
+	Region string
+	// Profile of the shared credentials file.
+	Profile string
+	// Endpoint replaces the AWS endpoints of all services, for example to
+	// use an S3 compatible store. S3 buckets are addressed path style then.
+	Endpoint string
+}
+
+// Client sends signed requests to AWS services.
+type Client struct {
+	region   string
+	endpoint string
+	creds    *Credentials
+	http     *http.Client
+}
+
+// Error is a non-success response of an AWS service.
+type Error struct {
+	Status  int
+	Code    string
+	Message string
+}
+
+func (e *Error) Error() string {
+	message := "aws: " + http.StatusText(e.Status)
+	if e.Code != "" {
+		message += ": " + e.Code
+	}
+
+	if e.Message != "" {
+		message += ": " + e.Message
+	}
+
+	return message
+}
+
+// NewClient loads the credentials and returns a client for the region.
+func NewClient(ctx context.Context, opts *Options) (*Client, error) {
+	region := opts.Region
+	for _, name := range []string{"AWS_REGION", "AWS_DEFAULT_REGION"} {
+		if region == "" {
+			region = os.Getenv(name)
+		}
+	}
+
+	if region == "" {
+		return nil, errors.New("aws region must be set")
+	}
+
+	creds, err := LoadCredentials(ctx, opts.Profile)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Client{
+		region:   region,
+		endpoint: strings.TrimSuffix(opts.Endpoint, "/"),
+		creds:    creds,
+		http:     &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+// escape encodes s as required for canonical requests, keeping slashes if
+// path is set.
+func escape(s string, path bool) string {
+	var buf strings.Builder
+
+	for i := 0; i < len(s); i++ {
+		b := s[i]
+
+		if 'A' <= b && b <= 'Z' || 'a' <= b && b <= 'z' || '0' <= b && b <= '9' ||
+			b == '-' || b == '_' || b == '.' || b == '~' || (path && b == '/') {
+			buf.WriteByte(b)
+			continue
+		}
+
+		buf.WriteString("%" + strings.ToUpper(hex.EncodeToString([]byte{b})))
+	}
+
+	return buf.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// sign adds a Signature Version 4 authorization header to req.
+func (c *Client) sign(req *http.Request, service string, body []byte, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	payloadHash := sha256Hex(body)
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	if c.creds.Token != "" {
+		req.Header.Set("X-Amz-Security-Token", c.creds.Token)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name := range req.Header {
+		lower := strings.ToLower(name)
+		if strings.HasPrefix(lower, "x-amz-") || lower == "content-type" {
+			headers[lower] = strings.TrimSpace(req.Header.Get(name))
+		}
+	}
+
+	var names []string
+	for name := range headers {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+
+	signedHeaders := strings.Join(names, ";")
+
+	query := req.URL.Query()
+
+	var keys []string
+	for key := range query {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	var params []string
+	for _, key := range keys {
+		values := query[key]
+		sort.Strings(values)
+
+		for _, value := range values {
+			params = append(params, escape(key, false)+"="+escape(value, false))
+		}
+	}
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+
+	canonical := strings.Join([]string{
+		req.Method,
+		path,
+		strings.Join(params, "&"),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + c.region + "/" + service + "/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonical))
+
+	key := hmacSHA256([]byte("AWS4"+c.creds.SecretAccessKey), date)
+	key = hmacSHA256(key, c.region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+c.creds.AccessKeyID+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+hex.EncodeToString(hmacSHA256(key, stringToSign)))
+}
+
+// serviceURL returns the endpoint of service, host is the AWS host name.
+func (c *Client) serviceURL(host string) string {
+	if c.endpoint != "" {
+		return c.endpoint
+	}
+
+	return "https://" + host
+}
+
+// do sends a signed request and returns the response body. parseError
+// extracts the error code and message of non-success responses.
+func (c *Client) do(ctx context.Context, service string, method string, u *url.URL, header http.Header, body []byte, parseError func([]byte, *Error)) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	for name, values := range header {
+		req.Header[name] = values
+	}
+
+	c.sign(req, service, body, time.Now())
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	content, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode/100 != 2 {
+		awsErr := &Error{Status: resp.StatusCode}
+		parseError(content, awsErr)
+
+		return nil, awsErr
+	}
+
+	return content, nil
+}
@@ -0,0 +1,226 @@
+// Package aws reads certificate and key pairs from AWS Secrets Manager and
+// S3, signing requests with Signature Version 4.
+package aws
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	metadataEndpoint  = "http://169.254.169.254"
+	containerEndpoint = "http://169.254.170.2"
+)
+
+// Credentials sign requests, Token is set for temporary credentials.
+type Credentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	Token           string
+}
+
+// LoadCredentials looks for credentials in the environment, the shared
+// credentials file, the ECS container endpoint and the EC2 instance metadata,
+// in that order. profile defaults to $AWS_PROFILE or "default".
+func LoadCredentials(ctx context.Context, profile string) (*Credentials, error) {
+	if id := os.Getenv("AWS_ACCESS_KEY_ID"); id != "" {
+		return &Credentials{
+			AccessKeyID:     id,
+			SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			Token:           os.Getenv("AWS_SESSION_TOKEN"),
+		}, nil
+	}
+
+	creds, err := sharedCredentials(profile)
+	if creds != nil || err != nil {
+		return creds, err
+	}
+
+	client := &http.Client{Timeout: 2 * time.Second}
+
+	if uri := os.Getenv("AWS_CONTAINER_CREDENTIALS_RELATIVE_URI"); uri != "" {
+		return fetchCredentials(ctx, client, containerEndpoint+uri, "")
+	}
+
+	creds, err = instanceCredentials(ctx, client)
+	if err != nil {
+		return nil, errors.New("no AWS credentials found: " + err.Error())
+	}
+
+	return creds, nil
+}
+
+// sharedCredentials reads profile from the shared credentials file. A
+// missing file or profile yields no credentials.
+func sharedCredentials(profile string) (*Credentials, error) {
+	if profile == "" {
+		profile = os.Getenv("AWS_PROFILE")
+	}
+
+	if profile == "" {
+		profile = "default"
+	}
+
+	path := os.Getenv("AWS_SHARED_CREDENTIALS_FILE")
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, nil
+		}
+
+		path = filepath.Join(home, ".aws", "credentials")
+	}
+
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	var creds *Credentials
+
+	section := ""
+	scanner := bufio.NewScanner(file)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+
+		if line[0] == '[' {
+			section = strings.TrimSpace(strings.Trim(line, "[]"))
+			continue
+		}
+
+		if section != profile {
+			continue
+		}
+
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+
+		if creds == nil {
+			creds = &Credentials{}
+		}
+
+		value := strings.TrimSpace(parts[1])
+
+		switch strings.TrimSpace(parts[0]) {
+		case "aws_access_key_id":
+			creds.AccessKeyID = value
+		case "aws_secret_access_key":
+			creds.SecretAccessKey = value
+		case "aws_session_token":
+			creds.Token = value
+		}
+	}
+
+	if creds != nil && creds.AccessKeyID == "" {
+		return nil, errors.New("profile " + profile + " in " + path + " has no aws_access_key_id")
+	}
+
+	return creds, scanner.Err()
+}
+
+// instanceCredentials fetches the credentials of the instance role using
+// IMDSv2.
+func instanceCredentials(ctx context.Context, client *http.Client) (*Credentials, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, metadataEndpoint+"/latest/api/token", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("X-aws-ec2-metadata-token-ttl-seconds", "60")
+
+	token, err := get(client, req)
+	if err != nil {
+		return nil, err
+	}
+
+	base := metadataEndpoint + "/latest/meta-data/iam/security-credentials/"
+
+	req, err = http.NewRequestWithContext(ctx, http.MethodGet, base, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("X-aws-ec2-metadata-token", string(token))
+
+	role, err := get(client, req)
+	if err != nil {
+		return nil, err
+	}
+
+	name := strings.TrimSpace(strings.SplitN(string(role), "\n", 2)[0])
+	if name == "" {
+		return nil, errors.New("instance has no IAM role")
+	}
+
+	return fetchCredentials(ctx, client, base+name, string(token))
+}
+
+func fetchCredentials(ctx context.Context, client *http.Client, url string, token string) (*Credentials, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if token != "" {
+		req.Header.Set("X-aws-ec2-metadata-token", token)
+	}
+
+	content, err := get(client, req)
+	if err != nil {
+		return nil, err
+	}
+
+	var response struct {
+		AccessKeyID     string `json:"AccessKeyId"`
+		SecretAccessKey string `json:"SecretAccessKey"`
+		Token           string `json:"Token"`
+	}
+
+	err = json.Unmarshal(content, &response)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Credentials{AccessKeyID: response.AccessKeyID, SecretAccessKey: response.SecretAccessKey, Token: response.Token}, nil
+}
+
+func get(client *http.Client, req *http.Request) ([]byte, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	content, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode/100 != 2 {
+		return nil, errors.New(req.URL.String() + ": " + resp.Status)
+	}
+
+	return content, nil
+}
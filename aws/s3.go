@@ -0,0 +1,131 @@
+package aws
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"net/http"
+	"net/url"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// maxObjectSize limits the objects downloaded from S3, certificates and keys
+// are much smaller.
+const maxObjectSize = 1 << 20
+
+// Object is a PEM file read from S3.
+type Object struct {
+	Key     string
+	Content []byte
+}
+
+// bucketURL returns the URL of key in bucket, virtual host style for AWS and
+// path style for custom endpoints.
+func (c *Client) bucketURL(bucket string, key string) (*url.URL, error) {
+	if c.endpoint != "" {
+		u, err := url.Parse(c.endpoint)
+		if err != nil {
+			return nil, err
+		}
+
+		u.Path = "/" + bucket
+		u.RawPath = "/" + escape(bucket, false)
+
+		if key != "" {
+			u.Path += "/" + key
+			u.RawPath += "/" + escape(key, true)
+		}
+
+		return u, nil
+	}
+
+	return &url.URL{
+		Scheme:  "https",
+		Host:    bucket + ".s3." + c.region + ".amazonaws.com",
+		Path:    "/" + key,
+		RawPath: "/" + escape(key, true),
+	}, nil
+}
+
+func (c *Client) s3(ctx context.Context, u *url.URL) ([]byte, error) {
+	return c.do(ctx, "s3", http.MethodGet, u, nil, nil, func(content []byte, awsErr *Error) {
+		var response struct {
+			Code    string
+			Message string
+		}
+
+		xml.Unmarshal(content, &response)
+
+		awsErr.Code = response.Code
+		awsErr.Message = response.Message
+	})
+}
+
+// ReadObjects downloads the PEM encoded objects below prefix in bucket.
+// Other objects are skipped.
+func (c *Client) ReadObjects(ctx context.Context, bucket string, prefix string) ([]Object, error) {
+	var objects []Object
+
+	token := ""
+
+	for {
+		u, err := c.bucketURL(bucket, "")
+		if err != nil {
+			return nil, err
+		}
+
+		u.RawQuery = "list-type=2&prefix=" + escape(prefix, false)
+		if token != "" {
+			u.RawQuery += "&continuation-token=" + escape(token, false)
+		}
+
+		content, err := c.s3(ctx, u)
+		if err != nil {
+			return nil, err
+		}
+
+		var list struct {
+			Contents []struct {
+				Key  string
+				Size int64
+			}
+			IsTruncated           bool
+			NextContinuationToken string
+		}
+
+		err = xml.Unmarshal(content, &list)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, entry := range list.Contents {
+			if entry.Size == 0 || entry.Size > maxObjectSize {
+				continue
+			}
+
+			u, err := c.bucketURL(bucket, entry.Key)
+			if err != nil {
+				return nil, err
+			}
+
+			content, err := c.s3(ctx, u)
+			if err != nil {
+				return nil, err
+			}
+
+			if !bytes.Contains(content, []byte("-----BEGIN ")) {
+				log.WithField("key", entry.Key).Debug("Skipping object without PEM data")
+				continue
+			}
+
+			objects = append(objects, Object{Key: entry.Key, Content: content})
+		}
+
+		if !list.IsTruncated || list.NextContinuationToken == "" {
+			return objects, nil
+		}
+
+		token = list.NextContinuationToken
+	}
+}
@@ -0,0 +1,160 @@
+package aws
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Pair is a certificate and key read from a secret.
+type Pair struct {
+	// Name is the name of the secret.
+	Name string
+	Cert []byte
+	Key  []byte
+}
+
+// secretsManager calls action of the Secrets Manager JSON API.
+func (c *Client) secretsManager(ctx context.Context, action string, request interface{}, response interface{}) error {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+
+	u, err := url.Parse(c.serviceURL("secretsmanager." + c.region + ".amazonaws.com"))
+	if err != nil {
+		return err
+	}
+
+	header := http.Header{}
+	header.Set("Content-Type", "application/x-amz-json-1.1")
+	header.Set("X-Amz-Target", "secretsmanager."+action)
+
+	content, err := c.do(ctx, "secretsmanager", http.MethodPost, u, header, body, func(content []byte, awsErr *Error) {
+		var response struct {
+			Type         string `json:"__type"`
+			Message      string `json:"message"`
+			MessageUpper string `json:"Message"`
+		}
+
+		json.Unmarshal(content, &response)
+
+		awsErr.Code = response.Type[strings.LastIndex(response.Type, "#")+1:]
+		awsErr.Message = response.Message + response.MessageUpper
+	})
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(content, response)
+}
+
+// field returns the first of the fields set in data.
+func field(data map[string]interface{}, names ...string) string {
+	for _, name := range names {
+		if value, ok := data[name].(string); ok && value != "" {
+			return value
+		}
+	}
+
+	return ""
+}
+
+// ReadSecretPairs reads the secrets whose name starts with prefix and that
+// carry the tag, given as KEY or KEY=VALUE. Both may be empty. Secrets must
+// hold a JSON object with a certificate and a private_key field.
+func (c *Client) ReadSecretPairs(ctx context.Context, prefix string, tag string) ([]Pair, error) {
+	type filter struct {
+		Key    string
+		Values []string
+	}
+
+	var filters []filter
+
+	if prefix != "" {
+		filters = append(filters, filter{Key: "name", Values: []string{prefix}})
+	}
+
+	if tag != "" {
+		parts := strings.SplitN(tag, "=", 2)
+		filters = append(filters, filter{Key: "tag-key", Values: []string{parts[0]}})
+
+		if len(parts) == 2 {
+			filters = append(filters, filter{Key: "tag-value", Values: []string{parts[1]}})
+		}
+	}
+
+	var pairs []Pair
+
+	nextToken := ""
+
+	for {
+		request := map[string]interface{}{"MaxResults": 100}
+		if len(filters) > 0 {
+			request["Filters"] = filters
+		}
+
+		if nextToken != "" {
+			request["NextToken"] = nextToken
+		}
+
+		var list struct {
+			SecretList []struct {
+				ARN  string
+				Name string
+			}
+			NextToken string
+		}
+
+		err := c.secretsManager(ctx, "ListSecrets", request, &list)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, secret := range list.SecretList {
+			// Prefix filters match words anywhere in the name.
+			if !strings.HasPrefix(secret.Name, prefix) {
+				continue
+			}
+
+			var value struct {
+				SecretString string
+			}
+
+			err := c.secretsManager(ctx, "GetSecretValue", map[string]string{"SecretId": secret.ARN}, &value)
+			if err != nil {
+				return nil, err
+			}
+
+			var data map[string]interface{}
+			if json.Unmarshal([]byte(value.SecretString), &data) != nil {
+				log.WithField("secret", secret.Name).Debug("Secret is not a JSON object")
+				continue
+			}
+
+			certPEM := field(data, "certificate", "cert", "tls.crt", "crt")
+			keyPEM := field(data, "private_key", "key", "tls.key")
+
+			if certPEM == "" || keyPEM == "" {
+				log.WithField("secret", secret.Name).Debug("Secret without certificate and key")
+				continue
+			}
+
+			if chain := field(data, "ca_chain", "chain"); chain != "" {
+				certPEM = strings.TrimRight(certPEM, "\n") + "\n" + chain
+			}
+
+			pairs = append(pairs, Pair{Name: secret.Name, Cert: []byte(certPEM), Key: []byte(keyPEM)})
+		}
+
+		if list.NextToken == "" {
+			return pairs, nil
+		}
+
+		nextToken = list.NextToken
+	}
+}
@@ -0,0 +1,123 @@
+// Package azure reads the certificates of an Azure Key Vault including their
+// private keys.
+package azure
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+const (
+	resource         = "https://vault.azure.net"
+	metadataEndpoint = "http://169.254.169.254/metadata/identity/oauth2/token"
+	loginEndpoint    = "https://login.microsoftonline.com/"
+)
+
+// Token returns an access token for Key Vault, trying a client secret or
+// federated token from the environment, the managed identity and the Azure
+// CLI, in that order.
+func Token(ctx context.Context) (string, error) {
+	tenant, clientID := os.Getenv("AZURE_TENANT_ID"), os.Getenv("AZURE_CLIENT_ID")
+
+	if tenant != "" && clientID != "" {
+		authority := loginEndpoint
+		if host := os.Getenv("AZURE_AUTHORITY_HOST"); host != "" {
+			authority = strings.TrimSuffix(host, "/") + "/"
+		}
+
+		form := url.Values{"client_id": {clientID}, "scope": {resource + "/.default"}, "grant_type": {"client_credentials"}}
+
+		if file := os.Getenv("AZURE_FEDERATED_TOKEN_FILE"); file != "" {
+			assertion, err := ioutil.ReadFile(file)
+			if err != nil {
+				return "", err
+			}
+
+			form.Set("client_assertion_type", "urn:ietf:params:oauth:client-assertion-type:jwt-bearer")
+			form.Set("client_assertion", strings.TrimSpace(string(assertion)))
+
+			return tokenRequest(ctx, &http.Client{Timeout: 30 * time.Second}, http.MethodPost, authority+tenant+"/oauth2/v2.0/token", form, nil)
+		}
+
+		if secret := os.Getenv("AZURE_CLIENT_SECRET"); secret != "" {
+			form.Set("client_secret", secret)
+
+			return tokenRequest(ctx, &http.Client{Timeout: 30 * time.Second}, http.MethodPost, authority+tenant+"/oauth2/v2.0/token", form, nil)
+		}
+	}
+
+	query := url.Values{"api-version": {"2018-02-01"}, "resource": {resource}}
+	if clientID != "" {
+		query.Set("client_id", clientID)
+	}
+
+	token, err := tokenRequest(ctx, &http.Client{Timeout: 2 * time.Second}, http.MethodGet, metadataEndpoint+"?"+query.Encode(), nil,
+		http.Header{"Metadata": {"true"}})
+	if err == nil {
+		return token, nil
+	}
+
+	output, cliErr := exec.CommandContext(ctx, "az", "account", "get-access-token", "--resource", resource, "--output", "json").Output()
+	if cliErr != nil {
+		return "", errors.New("no Azure credentials found: managed identity: " + err.Error() + ", azure cli: " + cliErr.Error())
+	}
+
+	var response struct {
+		AccessToken string `json:"accessToken"`
+	}
+
+	err = json.Unmarshal(output, &response)
+	if err != nil {
+		return "", err
+	}
+
+	return response.AccessToken, nil
+}
+
+func tokenRequest(ctx context.Context, client *http.Client, method string, endpoint string, form url.Values, header http.Header) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+
+	for name, values := range header {
+		req.Header[name] = values
+	}
+
+	if form != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+
+	defer resp.Body.Close()
+
+	var response struct {
+		AccessToken      string `json:"access_token"`
+		ErrorDescription string `json:"error_description"`
+	}
+
+	content, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+
+	json.Unmarshal(content, &response)
+
+	if resp.StatusCode/100 != 2 || response.AccessToken == "" {
+		return "", errors.New("azure: token request failed: " + resp.Status + " " + response.ErrorDescription)
+	}
+
+	return response.AccessToken, nil
+}
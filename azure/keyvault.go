@@ -0,0 +1,202 @@
+package azure
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/chrisxf/traefik-tls-config-gen/scanner"
+	log "github.com/sirupsen/logrus"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+const apiVersion = "7.4"
+
+// Client reads from a single Key Vault.
+type Client struct {
+	vaultURL string
+	token    string
+	http     *http.Client
+}
+
+// Error is a non-success response of Key Vault.
+type Error struct {
+	Status  int
+	Code    string
+	Message string
+}
+
+func (e *Error) Error() string {
+	return "azure: " + http.StatusText(e.Status) + ": " + e.Code + ": " + e.Message
+}
+
+// Pair is a certificate and key read from Key Vault.
+type Pair struct {
+	// Name is the name of the certificate.
+	Name string
+	Cert []byte
+	Key  []byte
+}
+
+// NewClient authenticates for the vault, given by name or URL.
+func NewClient(ctx context.Context, vault string) (*Client, error) {
+	if vault == "" {
+		return nil, errors.New("key vault must be set")
+	}
+
+	if !strings.Contains(vault, "://") {
+		vault = "https://" + vault + ".vault.azure.net"
+	}
+
+	token, err := Token(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Client{vaultURL: strings.TrimSuffix(vault, "/"), token: token, http: &http.Client{Timeout: 30 * time.Second}}, nil
+}
+
+func (c *Client) get(ctx context.Context, endpoint string, response interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+c.token)
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	content, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode/100 != 2 {
+		var body struct {
+			Error struct {
+				Code    string `json:"code"`
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+
+		json.Unmarshal(content, &body)
+
+		return &Error{Status: resp.StatusCode, Code: body.Error.Code, Message: body.Error.Message}
+	}
+
+	return json.Unmarshal(content, response)
+}
+
+// ReadPairs reads the enabled certificates of the vault carrying the tag,
+// given as KEY or KEY=VALUE, or all if tag is empty. The private key is read
+// from the secret backing each certificate, so the certificate policy must
+// allow exporting it.
+func (c *Client) ReadPairs(ctx context.Context, tag string) ([]Pair, error) {
+	tagParts := strings.SplitN(tag, "=", 2)
+
+	var pairs []Pair
+
+	next := c.vaultURL + "/certificates?api-version=" + apiVersion
+
+	for next != "" {
+		var list struct {
+			Value []struct {
+				ID         string            `json:"id"`
+				Tags       map[string]string `json:"tags"`
+				Attributes struct {
+					Enabled bool `json:"enabled"`
+				} `json:"attributes"`
+			} `json:"value"`
+			NextLink string `json:"nextLink"`
+		}
+
+		err := c.get(ctx, next, &list)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, cert := range list.Value {
+			name := cert.ID[strings.LastIndex(cert.ID, "/")+1:]
+
+			if !cert.Attributes.Enabled {
+				continue
+			}
+
+			if value, ok := cert.Tags[tagParts[0]]; tag != "" && (!ok || len(tagParts) == 2 && value != tagParts[1]) {
+				continue
+			}
+
+			var secret struct {
+				Value       string `json:"value"`
+				ContentType string `json:"contentType"`
+			}
+
+			err := c.get(ctx, c.vaultURL+"/secrets/"+url.PathEscape(name)+"?api-version="+apiVersion, &secret)
+			if err != nil {
+				return nil, err
+			}
+
+			certPEM, keyPEM, err := decodeSecret(secret.Value, secret.ContentType)
+			if err != nil {
+				log.WithFields(log.Fields{"certificate": name, "error": err}).Warn("Could not decode key vault certificate")
+				continue
+			}
+
+			pairs = append(pairs, Pair{Name: name, Cert: certPEM, Key: keyPEM})
+		}
+
+		next = list.NextLink
+	}
+
+	return pairs, nil
+}
+
+// decodeSecret returns the certificates and key of a secret backing a
+// certificate, either a PEM bundle or a base64 encoded PKCS#12 file.
+func decodeSecret(value string, contentType string) ([]byte, []byte, error) {
+	if contentType != "application/x-pkcs12" {
+		certPEM, keyPEM := scanner.SplitPEM([]byte(value))
+		if certPEM == nil || keyPEM == nil {
+			return nil, nil, errors.New("secret holds no certificate and key")
+		}
+
+		return certPEM, keyPEM, nil
+	}
+
+	der, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pkey, leaf, chain, err := pkcs12.DecodeChain(der, "")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	certBuf := &bytes.Buffer{}
+
+	for _, cert := range append([]*x509.Certificate{leaf}, chain...) {
+		pem.Encode(certBuf, &pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+	}
+
+	keyDER, err := x509.MarshalPKCS8PrivateKey(pkey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return certBuf.Bytes(), pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), nil
+}
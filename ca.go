@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/chrisxf/traefik-tls-config-gen/certgen"
+	"github.com/chrisxf/traefik-tls-config-gen/tlsconfig"
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli"
+)
+
+// defaultCADir returns the directory of the local CA, below the user config
+// directory.
+func defaultCADir() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "ca"
+	}
+
+	return filepath.Join(dir, "traefik-tls-config-gen", "ca")
+}
+
+// caFlags select the local CA of the ca commands.
+var caFlags = []cli.Flag{
+	cli.StringFlag{
+		Name:  "ca-dir",
+		Value: defaultCADir(),
+		Usage: "Directory of the local CA certificate (" + certgen.CACertFile + ") and key (" + certgen.CAKeyFile + "), keep it out of the scanned directories",
+	},
+}
+
+func runCAInit(c *cli.Context) {
+	dir := c.String("ca-dir")
+	certFile, keyFile := filepath.Join(dir, certgen.CACertFile), filepath.Join(dir, certgen.CAKeyFile)
+
+	if _, err := os.Stat(certFile); err == nil {
+		log.Fatal("CA already exists in " + dir)
+	}
+
+	certPEM, keyPEM, err := certgen.NewCA(c.String("name"), c.Duration("validity"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	err = os.MkdirAll(dir, 0700)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	err = ioutil.WriteFile(keyFile, keyPEM, 0600)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	err = ioutil.WriteFile(certFile, certPEM, 0644)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	log.WithField("path", certFile).Info("Created CA, add the certificate to the trust stores of the clients")
+}
+
+func runCAIssue(c *cli.Context) {
+	if len(c.Args()) == 0 {
+		log.Fatal("Insufficient arguments!")
+	}
+
+	if !c.IsSet("cert-dir") {
+		log.Fatal("Certificate directory not set!")
+	}
+
+	ctx, stop := signalContext()
+	defer stop()
+
+	err := issue(ctx, c)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// issue writes a certificate for the domain arguments signed by the local CA
+// to --cert-dir and generates the config of that directory if an output is
+// set.
+func issue(ctx context.Context, c *cli.Context) error {
+	dir := c.String("ca-dir")
+
+	ca, err := certgen.LoadCA(filepath.Join(dir, certgen.CACertFile), filepath.Join(dir, certgen.CAKeyFile))
+	if os.IsNotExist(err) {
+		return errors.New("no CA in " + dir + ", run ca init first")
+	}
+
+	if err != nil {
+		return err
+	}
+
+	domains := []string(c.Args())
+
+	name := c.String("name")
+	if name == "" {
+		name = strings.NewReplacer("*", "wildcard", ":", "_").Replace(strings.ToLower(domains[0]))
+	}
+
+	certPEM, keyPEM, err := certgen.Issue(domains, c.Duration("validity"), ca)
+	if err != nil {
+		return err
+	}
+
+	certDir := c.String("cert-dir")
+
+	err = os.MkdirAll(certDir, 0755)
+	if err != nil {
+		return err
+	}
+
+	err = ioutil.WriteFile(filepath.Join(certDir, name+".key"), keyPEM, 0600)
+	if err != nil {
+		return err
+	}
+
+	certFile := filepath.Join(certDir, name+".crt")
+
+	err = ioutil.WriteFile(certFile, certPEM, 0644)
+	if err != nil {
+		return err
+	}
+
+	log.WithFields(log.Fields{"path": certFile, "domains": strings.Join(domains, ",")}).Info("Issued certificate")
+
+	if !c.IsSet("out") && !c.IsSet("push-url") && !c.IsSet("kv") {
+		return nil
+	}
+
+	n, err := newNotifier(c)
+	if err != nil {
+		return err
+	}
+
+	opts, err := loadOptions(c)
+	if err != nil {
+		return err
+	}
+
+	// The arguments are the domains, only the certificate directory is
+	// scanned.
+	opts.Dirs = []tlsconfig.Dir{{Path: certDir}}
+
+	ctx, cancel := runContext(ctx, c)
+	defer cancel()
+
+	result, err := tlsconfig.Generate(ctx, opts)
+	if err != nil {
+		return err
+	}
+
+	fmt.Print(result.Diff)
+
+	return afterWrite(ctx, c, n, opts.Out, result)
+}
+
+var caCommand = cli.Command{
+	Name:  "ca",
+	Usage: "Maintain a local CA issuing certificates for development setups",
+	Subcommands: []cli.Command{
+		{
+			Name:  "init",
+			Usage: "Create the CA certificate and key",
+			Flags: flags(caFlags, []cli.Flag{
+				cli.StringFlag{
+					Name:  "name",
+					Value: "traefik-tls-config-gen development CA",
+					Usage: "Common name of the CA certificate",
+				},
+				cli.DurationFlag{
+					Name:  "validity",
+					Value: 10 * 365 * 24 * time.Hour,
+					Usage: "Validity of the CA certificate",
+				},
+			}),
+			Action: runCAInit,
+		},
+		{
+			Name:      "issue",
+			Usage:     "Issue a certificate for the domains and IP addresses and generate the config of the certificate directory",
+			ArgsUsage: "domain...",
+			Flags: flags(caFlags, outputFlags, renderFlags, hookFlags, []cli.Flag{
+				cli.StringFlag{
+					Name:  "cert-dir",
+					Usage: "Directory the certificate and key are written to",
+				},
+				cli.StringFlag{
+					Name:  "name",
+					Usage: "File name of the certificate and key without extension, derived from the first domain if empty",
+				},
+				cli.DurationFlag{
+					Name:  "validity",
+					Value: 397 * 24 * time.Hour,
+					Usage: "Validity of the certificate",
+				},
+			}),
+			Action: runCAIssue,
+		},
+	},
+}
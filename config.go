@@ -0,0 +1,167 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/urfave/cli"
+	"gopkg.in/yaml.v2"
+)
+
+// envPrefix is prepended to the upper-cased flag names, with dashes replaced
+// by underscores, to form the environment variable overriding the config file.
+const envPrefix = "TLSGEN_"
+
+// flagName returns the long name of flag.
+func flagName(flag cli.Flag) string {
+	return strings.TrimSpace(strings.Split(flag.GetName(), ",")[0])
+}
+
+func envName(name string) string {
+	return envPrefix + strings.ToUpper(strings.Replace(name, "-", "_", -1))
+}
+
+// loadConfigFile reads the YAML or TOML config file, picked by extension,
+// mapping flag names to values or lists of values.
+func loadConfigFile(file string) (map[string]interface{}, error) {
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	values := map[string]interface{}{}
+
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(content, &values)
+	case ".toml":
+		_, err = toml.Decode(string(content), &values)
+	default:
+		err = errors.New("unknown config file format " + filepath.Ext(file))
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return values, nil
+}
+
+// allFlagNames returns the names of the flags of the app and all commands.
+func allFlagNames(app *cli.App) map[string]bool {
+	names := map[string]bool{}
+	for _, flag := range app.Flags {
+		names[flagName(flag)] = true
+	}
+
+	pending := app.Commands
+	for len(pending) > 0 {
+		command := pending[0]
+		pending = append(pending[1:], command.Subcommands...)
+
+		for _, flag := range command.Flags {
+			names[flagName(flag)] = true
+		}
+	}
+
+	return names
+}
+
+// applyConfig sets the flags not given on the command line from their
+// environment variable or else from the --config file.
+func applyConfig(c *cli.Context, flags []cli.Flag) error {
+	var values map[string]interface{}
+
+	if file := c.GlobalString("config"); file != "" {
+		var err error
+
+		values, err = loadConfigFile(file)
+		if err != nil {
+			return errors.New("config file " + file + ": " + err.Error())
+		}
+	}
+
+	for _, flag := range flags {
+		name := flagName(flag)
+		if c.IsSet(name) {
+			continue
+		}
+
+		if value, ok := os.LookupEnv(envName(name)); ok {
+			err := c.Set(name, value)
+			if err != nil {
+				return errors.New(envName(name) + ": " + err.Error())
+			}
+
+			continue
+		}
+
+		value, ok := values[name]
+		if !ok {
+			continue
+		}
+
+		list, ok := value.([]interface{})
+		if !ok {
+			list = []interface{}{value}
+		}
+
+		for _, item := range list {
+			switch item.(type) {
+			case map[interface{}]interface{}, map[string]interface{}, []interface{}:
+				return errors.New("config file option " + name + " must be a value or a list of values")
+			}
+
+			err := c.Set(name, fmt.Sprint(item))
+			if err != nil {
+				return errors.New("config file option " + name + ": " + err.Error())
+			}
+		}
+	}
+
+	return nil
+}
+
+// checkConfigFile reports options of the --config file that are no flag of
+// any command.
+func checkConfigFile(c *cli.Context) error {
+	file := c.GlobalString("config")
+	if file == "" {
+		return nil
+	}
+
+	values, err := loadConfigFile(file)
+	if err != nil {
+		return errors.New("config file " + file + ": " + err.Error())
+	}
+
+	known := allFlagNames(c.App)
+
+	for name := range values {
+		if !known[name] {
+			return errors.New("unknown option " + name + " in config file " + file)
+		}
+	}
+
+	return nil
+}
+
+// withConfig makes the commands apply the config to their flags before
+// running.
+func withConfig(commands []cli.Command) {
+	for i := range commands {
+		command := &commands[i]
+		flags := command.Flags
+
+		command.Before = func(c *cli.Context) error {
+			return applyConfig(c, flags)
+		}
+
+		withConfig(command.Subcommands)
+	}
+}
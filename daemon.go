@@ -0,0 +1,30 @@
+package main
+
+import (
+	"net"
+	"os"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// sdNotify sends state to the service manager if started by systemd with
+// Type=notify, see sd_notify(3).
+func sdNotify(state string) {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		log.WithError(err).Warn("Could not notify service manager")
+		return
+	}
+
+	defer conn.Close()
+
+	_, err = conn.Write([]byte(state))
+	if err != nil {
+		log.WithError(err).Warn("Could not notify service manager")
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const DefaultDockerHost = "unix:///var/run/docker.sock"
+
+// newDockerClient returns a HTTP client talking to the Docker Engine API at
+// host along with the base URL to use for requests.
+func newDockerClient(host string) (*http.Client, string, error) {
+	if host == "" {
+		host = os.Getenv("DOCKER_HOST")
+	}
+
+	if host == "" {
+		host = DefaultDockerHost
+	}
+
+	u, err := url.Parse(host)
+	if err != nil {
+		return nil, "", err
+	}
+
+	switch u.Scheme {
+	case "unix":
+		transport := &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var dialer net.Dialer
+				return dialer.DialContext(ctx, "unix", u.Path)
+			},
+		}
+
+		return &http.Client{Transport: transport, Timeout: 30 * time.Second}, "http://docker", nil
+	case "tcp", "http":
+		return &http.Client{Timeout: 30 * time.Second}, "http://" + u.Host, nil
+	default:
+		return nil, "", errors.New("unsupported docker host " + host)
+	}
+}
+
+// reloadContainer sends the given signal to the container, or restarts it if
+// signal is "restart".
+func reloadContainer(host string, container string, signal string) error {
+	client, base, err := newDockerClient(host)
+	if err != nil {
+		return err
+	}
+
+	endpoint := base + "/containers/" + url.PathEscape(container)
+
+	if signal == "restart" {
+		endpoint += "/restart"
+	} else {
+		endpoint += "/kill?signal=" + url.QueryEscape(strings.TrimPrefix(strings.ToUpper(signal), "SIG"))
+	}
+
+	log.WithFields(log.Fields{"container": container, "signal": signal}).Info("Reloading container")
+
+	res, err := client.Post(endpoint, "application/json", nil)
+	if err != nil {
+		return err
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusNoContent && res.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(res.Body)
+		return errors.New("docker: " + res.Status + ": " + strings.TrimSpace(string(body)))
+	}
+
+	return nil
+}
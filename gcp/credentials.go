@@ -0,0 +1,222 @@
+// Package gcp reads certificate and key pairs stored in Google Cloud Secret
+// Manager.
+package gcp
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	scope            = "https://www.googleapis.com/auth/cloud-platform"
+	defaultTokenURI  = "https://oauth2.googleapis.com/token"
+	metadataEndpoint = "http://metadata.google.internal/computeMetadata/v1/"
+)
+
+type credentialsFile struct {
+	Type         string `json:"type"`
+	ProjectID    string `json:"project_id"`
+	ClientEmail  string `json:"client_email"`
+	PrivateKey   string `json:"private_key"`
+	TokenURI     string `json:"token_uri"`
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// Credentials is an access token and the project it belongs to, if known.
+type Credentials struct {
+	Token   string
+	Project string
+}
+
+// LoadCredentials follows the application default credentials: the file in
+// $GOOGLE_APPLICATION_CREDENTIALS, the one written by gcloud and the
+// metadata server, in that order.
+func LoadCredentials(ctx context.Context) (*Credentials, error) {
+	path := os.Getenv("GOOGLE_APPLICATION_CREDENTIALS")
+	if path == "" {
+		if dir, err := os.UserConfigDir(); err == nil {
+			path = filepath.Join(dir, "gcloud", "application_default_credentials.json")
+			if _, err := os.Stat(path); err != nil {
+				path = ""
+			}
+		}
+	}
+
+	if path != "" {
+		return fileCredentials(ctx, path)
+	}
+
+	client := &http.Client{Timeout: 2 * time.Second}
+
+	content, err := metadata(ctx, client, "instance/service-accounts/default/token")
+	if err != nil {
+		return nil, errors.New("no Google Cloud credentials found: " + err.Error())
+	}
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+	}
+
+	err = json.Unmarshal(content, &token)
+	if err != nil {
+		return nil, err
+	}
+
+	project, _ := metadata(ctx, client, "project/project-id")
+
+	return &Credentials{Token: token.AccessToken, Project: string(project)}, nil
+}
+
+func fileCredentials(ctx context.Context, path string) (*Credentials, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var file credentialsFile
+
+	err = json.Unmarshal(content, &file)
+	if err != nil {
+		return nil, errors.New(path + ": " + err.Error())
+	}
+
+	tokenURI := file.TokenURI
+	if tokenURI == "" {
+		tokenURI = defaultTokenURI
+	}
+
+	var form url.Values
+
+	switch file.Type {
+	case "service_account":
+		assertion, err := signJWT(&file, tokenURI, time.Now())
+		if err != nil {
+			return nil, errors.New(path + ": " + err.Error())
+		}
+
+		form = url.Values{"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"}, "assertion": {assertion}}
+	case "authorized_user":
+		form = url.Values{
+			"grant_type":    {"refresh_token"},
+			"client_id":     {file.ClientID},
+			"client_secret": {file.ClientSecret},
+			"refresh_token": {file.RefreshToken},
+		}
+	default:
+		return nil, errors.New(path + ": credentials of type " + file.Type + " are not supported")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenURI, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := (&http.Client{Timeout: 30 * time.Second}).Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	var token struct {
+		AccessToken      string `json:"access_token"`
+		ErrorDescription string `json:"error_description"`
+	}
+
+	json.NewDecoder(resp.Body).Decode(&token)
+
+	if resp.StatusCode/100 != 2 || token.AccessToken == "" {
+		return nil, errors.New("gcp: token request failed: " + resp.Status + " " + token.ErrorDescription)
+	}
+
+	project := os.Getenv("GOOGLE_CLOUD_PROJECT")
+	if project == "" {
+		project = file.ProjectID
+	}
+
+	return &Credentials{Token: token.AccessToken, Project: project}, nil
+}
+
+// signJWT returns the assertion exchanging a service account key for an
+// access token.
+func signJWT(file *credentialsFile, audience string, now time.Time) (string, error) {
+	block, _ := pem.Decode([]byte(file.PrivateKey))
+	if block == nil {
+		return "", errors.New("no private key found")
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return "", err
+	}
+
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return "", errors.New("service account key is not an RSA key")
+	}
+
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	claims, _ := json.Marshal(map[string]interface{}{
+		"iss":   file.ClientEmail,
+		"scope": scope,
+		"aud":   audience,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(unsigned))
+
+	signature, err := rsa.SignPKCS1v15(rand.Reader, rsaKey, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+func metadata(ctx context.Context, client *http.Client, path string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, metadataEndpoint+path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Metadata-Flavor", "Google")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	content, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode/100 != 2 {
+		return nil, errors.New("metadata server: " + resp.Status)
+	}
+
+	return content, nil
+}
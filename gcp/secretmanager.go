@@ -0,0 +1,195 @@
+package gcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/chrisxf/traefik-tls-config-gen/scanner"
+	log "github.com/sirupsen/logrus"
+)
+
+const defaultEndpoint = "https://secretmanager.googleapis.com"
+
+// Options select the project and the API endpoint.
+type Options struct {
+	// Project defaults to the project of the credentials.
+	Project string
+	// Endpoint replaces the Secret Manager endpoint.
+	Endpoint string
+}
+
+// Client reads the secrets of a project.
+type Client struct {
+	endpoint string
+	project  string
+	token    string
+	http     *http.Client
+}
+
+// Error is a non-success response of Secret Manager.
+type Error struct {
+	Status  int
+	Message string
+}
+
+func (e *Error) Error() string {
+	return "gcp: " + http.StatusText(e.Status) + ": " + e.Message
+}
+
+// Pair is a certificate and key read from a secret.
+type Pair struct {
+	// Name is the ID of the secret.
+	Name string
+	Cert []byte
+	Key  []byte
+}
+
+// NewClient loads the application default credentials.
+func NewClient(ctx context.Context, opts *Options) (*Client, error) {
+	creds, err := LoadCredentials(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	project := opts.Project
+	if project == "" {
+		project = creds.Project
+	}
+
+	if project == "" {
+		return nil, errors.New("google cloud project must be set")
+	}
+
+	endpoint := opts.Endpoint
+	if endpoint == "" {
+		endpoint = defaultEndpoint
+	}
+
+	return &Client{
+		endpoint: strings.TrimSuffix(endpoint, "/"),
+		project:  project,
+		token:    creds.Token,
+		http:     &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+func (c *Client) get(ctx context.Context, path string, response interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.endpoint+"/v1/"+path, nil)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+c.token)
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	content, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode/100 != 2 {
+		var body struct {
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+
+		json.Unmarshal(content, &body)
+
+		return &Error{Status: resp.StatusCode, Message: body.Error.Message}
+	}
+
+	return json.Unmarshal(content, response)
+}
+
+// decodePayload accepts a JSON object with a certificate and a private_key
+// field or a PEM bundle.
+func decodePayload(payload []byte) ([]byte, []byte) {
+	var data map[string]string
+	if json.Unmarshal(payload, &data) == nil {
+		cert := data["certificate"]
+		if chain := data["chain"]; chain != "" {
+			cert = strings.TrimRight(cert, "\n") + "\n" + chain
+		}
+
+		if cert == "" || data["private_key"] == "" {
+			return nil, nil
+		}
+
+		return []byte(cert), []byte(data["private_key"])
+	}
+
+	return scanner.SplitPEM(payload)
+}
+
+// ReadPairs reads the latest version of the secrets matching filter, in the
+// syntax of the Secret Manager API, for example "labels.tls=true".
+func (c *Client) ReadPairs(ctx context.Context, filter string) ([]Pair, error) {
+	var pairs []Pair
+
+	pageToken := ""
+
+	for {
+		query := url.Values{"pageSize": {"100"}}
+		if filter != "" {
+			query.Set("filter", filter)
+		}
+
+		if pageToken != "" {
+			query.Set("pageToken", pageToken)
+		}
+
+		var list struct {
+			Secrets []struct {
+				Name string `json:"name"`
+			} `json:"secrets"`
+			NextPageToken string `json:"nextPageToken"`
+		}
+
+		err := c.get(ctx, "projects/"+url.PathEscape(c.project)+"/secrets?"+query.Encode(), &list)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, secret := range list.Secrets {
+			var version struct {
+				Payload struct {
+					Data []byte `json:"data"`
+				} `json:"payload"`
+			}
+
+			err := c.get(ctx, secret.Name+"/versions/latest:access", &version)
+			if err != nil {
+				return nil, err
+			}
+
+			name := secret.Name[strings.LastIndex(secret.Name, "/")+1:]
+
+			cert, key := decodePayload(version.Payload.Data)
+			if cert == nil || key == nil {
+				log.WithField("secret", name).Debug("Secret without certificate and key")
+				continue
+			}
+
+			pairs = append(pairs, Pair{Name: name, Cert: cert, Key: key})
+		}
+
+		if list.NextPageToken == "" {
+			return pairs, nil
+		}
+
+		pageToken = list.NextPageToken
+	}
+}
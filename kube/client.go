@@ -0,0 +1,116 @@
+package kube
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Client sends requests to the API server of Config.
+type Client struct {
+	config *Config
+	http   *http.Client
+}
+
+// APIError is a non-success response of the API server.
+type APIError struct {
+	Status  int
+	Message string
+}
+
+func (e *APIError) Error() string {
+	return "kubernetes: " + http.StatusText(e.Status) + ": " + e.Message
+}
+
+// IsNotFound reports whether err is a 404 response.
+func IsNotFound(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.Status == http.StatusNotFound
+}
+
+func NewClient(config *Config) *Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = config.TLS
+
+	return &Client{
+		config: config,
+		http:   &http.Client{Transport: transport, Timeout: 30 * time.Second},
+	}
+}
+
+// Namespace returns the namespace of the config, "default" if none is set.
+func (c *Client) Namespace() string {
+	if c.config.Namespace == "" {
+		return "default"
+	}
+
+	return c.config.Namespace
+}
+
+// do sends request as JSON to path and decodes the response into response,
+// if it is not nil.
+func (c *Client) do(ctx context.Context, method string, path string, request interface{}, response interface{}) error {
+	var body io.Reader
+
+	if request != nil {
+		content, err := json.Marshal(request)
+		if err != nil {
+			return err
+		}
+
+		body = bytes.NewReader(content)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(c.config.Server, "/")+path, body)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Accept", "application/json")
+
+	if request != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	if c.config.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.config.Token)
+	} else if c.config.Username != "" {
+		req.SetBasicAuth(c.config.Username, c.config.Password)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	content, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode/100 != 2 {
+		var status struct {
+			Message string `json:"message"`
+		}
+
+		if json.Unmarshal(content, &status) != nil || status.Message == "" {
+			status.Message = strings.TrimSpace(string(content))
+		}
+
+		return &APIError{Status: resp.StatusCode, Message: status.Message}
+	}
+
+	if response == nil {
+		return nil
+	}
+
+	return json.Unmarshal(content, response)
+}
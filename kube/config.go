@@ -0,0 +1,236 @@
+// Package kube talks to the Kubernetes API to manage the TLS secrets
+// generated from the scanned pairs.
+package kube
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+const serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// Config is the connection to an API server.
+type Config struct {
+	Server string
+	// Token, or Username and Password, authenticate requests, unless a
+	// client certificate is set in TLS.
+	Token     string
+	Username  string
+	Password  string
+	TLS       *tls.Config
+	Namespace string
+}
+
+type kubeconfig struct {
+	CurrentContext string `yaml:"current-context"`
+	Clusters       []struct {
+		Name    string `yaml:"name"`
+		Cluster struct {
+			Server                   string `yaml:"server"`
+			CertificateAuthority     string `yaml:"certificate-authority"`
+			CertificateAuthorityData string `yaml:"certificate-authority-data"`
+			InsecureSkipTLSVerify    bool   `yaml:"insecure-skip-tls-verify"`
+		} `yaml:"cluster"`
+	} `yaml:"clusters"`
+	Contexts []struct {
+		Name    string `yaml:"name"`
+		Context struct {
+			Cluster   string `yaml:"cluster"`
+			User      string `yaml:"user"`
+			Namespace string `yaml:"namespace"`
+		} `yaml:"context"`
+	} `yaml:"contexts"`
+	Users []struct {
+		Name string `yaml:"name"`
+		User struct {
+			Token                 string      `yaml:"token"`
+			TokenFile             string      `yaml:"tokenFile"`
+			Username              string      `yaml:"username"`
+			Password              string      `yaml:"password"`
+			ClientCertificate     string      `yaml:"client-certificate"`
+			ClientCertificateData string      `yaml:"client-certificate-data"`
+			ClientKey             string      `yaml:"client-key"`
+			ClientKeyData         string      `yaml:"client-key-data"`
+			Exec                  interface{} `yaml:"exec"`
+			AuthProvider          interface{} `yaml:"auth-provider"`
+		} `yaml:"user"`
+	} `yaml:"users"`
+}
+
+// LoadConfig returns the in-cluster config when running in a pod and path is
+// empty, the current context of the kubeconfig file otherwise. path defaults
+// to $KUBECONFIG or ~/.kube/config.
+func LoadConfig(path string) (*Config, error) {
+	if path == "" && os.Getenv("KUBERNETES_SERVICE_HOST") != "" {
+		return inClusterConfig()
+	}
+
+	if path == "" {
+		path = strings.Split(os.Getenv("KUBECONFIG"), string(os.PathListSeparator))[0]
+	}
+
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, err
+		}
+
+		path = filepath.Join(home, ".kube", "config")
+	}
+
+	return loadKubeconfig(path)
+}
+
+func inClusterConfig() (*Config, error) {
+	token, err := ioutil.ReadFile(filepath.Join(serviceAccountDir, "token"))
+	if err != nil {
+		return nil, err
+	}
+
+	ca, err := ioutil.ReadFile(filepath.Join(serviceAccountDir, "ca.crt"))
+	if err != nil {
+		return nil, err
+	}
+
+	namespace, _ := ioutil.ReadFile(filepath.Join(serviceAccountDir, "namespace"))
+
+	roots := x509.NewCertPool()
+	roots.AppendCertsFromPEM(ca)
+
+	host := net.JoinHostPort(os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT"))
+
+	return &Config{
+		Server:    "https://" + host,
+		Token:     strings.TrimSpace(string(token)),
+		TLS:       &tls.Config{RootCAs: roots},
+		Namespace: strings.TrimSpace(string(namespace)),
+	}, nil
+}
+
+// fileOrData returns the decoded data if set, the content of the file
+// relative to dir otherwise.
+func fileOrData(dir string, file string, data string) ([]byte, error) {
+	if data != "" {
+		return base64.StdEncoding.DecodeString(data)
+	}
+
+	if file == "" {
+		return nil, nil
+	}
+
+	if !filepath.IsAbs(file) {
+		file = filepath.Join(dir, file)
+	}
+
+	return ioutil.ReadFile(file)
+}
+
+func loadKubeconfig(path string) (*Config, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var kc kubeconfig
+
+	err = yaml.Unmarshal(content, &kc)
+	if err != nil {
+		return nil, err
+	}
+
+	dir := filepath.Dir(path)
+	config := &Config{TLS: &tls.Config{}}
+
+	var clusterName, userName string
+
+	for _, ctx := range kc.Contexts {
+		if ctx.Name == kc.CurrentContext {
+			clusterName, userName = ctx.Context.Cluster, ctx.Context.User
+			config.Namespace = ctx.Context.Namespace
+		}
+	}
+
+	if clusterName == "" {
+		return nil, errors.New("kubeconfig " + path + ": current context not found")
+	}
+
+	for _, cluster := range kc.Clusters {
+		if cluster.Name != clusterName {
+			continue
+		}
+
+		config.Server = cluster.Cluster.Server
+		config.TLS.InsecureSkipVerify = cluster.Cluster.InsecureSkipTLSVerify
+
+		ca, err := fileOrData(dir, cluster.Cluster.CertificateAuthority, cluster.Cluster.CertificateAuthorityData)
+		if err != nil {
+			return nil, err
+		}
+
+		if ca != nil {
+			config.TLS.RootCAs = x509.NewCertPool()
+			config.TLS.RootCAs.AppendCertsFromPEM(ca)
+		}
+	}
+
+	if config.Server == "" {
+		return nil, errors.New("kubeconfig " + path + ": cluster " + clusterName + " not found")
+	}
+
+	for _, user := range kc.Users {
+		if user.Name != userName {
+			continue
+		}
+
+		if user.User.Exec != nil {
+			return nil, errors.New("kubeconfig " + path + ": exec credential plugins are not supported")
+		}
+
+		if user.User.AuthProvider != nil {
+			return nil, errors.New("kubeconfig " + path + ": auth-provider plugins are not supported")
+		}
+
+		config.Token = user.User.Token
+		config.Username = user.User.Username
+		config.Password = user.User.Password
+
+		if user.User.TokenFile != "" {
+			token, err := fileOrData(dir, user.User.TokenFile, "")
+			if err != nil {
+				return nil, err
+			}
+
+			config.Token = strings.TrimSpace(string(token))
+		}
+
+		certPEM, err := fileOrData(dir, user.User.ClientCertificate, user.User.ClientCertificateData)
+		if err != nil {
+			return nil, err
+		}
+
+		keyPEM, err := fileOrData(dir, user.User.ClientKey, user.User.ClientKeyData)
+		if err != nil {
+			return nil, err
+		}
+
+		if certPEM != nil && keyPEM != nil {
+			cert, err := tls.X509KeyPair(certPEM, keyPEM)
+			if err != nil {
+				return nil, err
+			}
+
+			config.TLS.Certificates = []tls.Certificate{cert}
+		}
+	}
+
+	return config, nil
+}
@@ -0,0 +1,172 @@
+package kube
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"sort"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// ManagedByLabel marks the secrets created by this tool, only those are
+	// updated and pruned.
+	ManagedByLabel = "app.kubernetes.io/managed-by"
+	ManagedBy      = "traefik-tls-config-gen"
+	// SourceAnnotation holds the path of the certificate a secret was
+	// created from.
+	SourceAnnotation = "traefik-tls-config-gen/source"
+)
+
+type ObjectMeta struct {
+	Name            string            `json:"name"`
+	Namespace       string            `json:"namespace,omitempty"`
+	ResourceVersion string            `json:"resourceVersion,omitempty"`
+	Labels          map[string]string `json:"labels,omitempty"`
+	Annotations     map[string]string `json:"annotations,omitempty"`
+}
+
+type Secret struct {
+	APIVersion string            `json:"apiVersion"`
+	Kind       string            `json:"kind"`
+	Metadata   ObjectMeta        `json:"metadata"`
+	Type       string            `json:"type"`
+	Data       map[string][]byte `json:"data"`
+}
+
+type secretList struct {
+	Items []Secret `json:"items"`
+}
+
+// NewTLSSecret returns a kubernetes.io/tls secret labeled as managed by this
+// tool.
+func NewTLSSecret(name string, cert []byte, key []byte, source string) Secret {
+	return Secret{
+		APIVersion: "v1",
+		Kind:       "Secret",
+		Metadata: ObjectMeta{
+			Name:        name,
+			Labels:      map[string]string{ManagedByLabel: ManagedBy},
+			Annotations: map[string]string{SourceAnnotation: source},
+		},
+		Type: "kubernetes.io/tls",
+		Data: map[string][]byte{"tls.crt": cert, "tls.key": key},
+	}
+}
+
+// equal reports whether the secrets have the same type, data and source.
+func (s *Secret) equal(other *Secret) bool {
+	if s.Type != other.Type || len(s.Data) != len(other.Data) ||
+		s.Metadata.Annotations[SourceAnnotation] != other.Metadata.Annotations[SourceAnnotation] {
+		return false
+	}
+
+	for key, value := range s.Data {
+		if !bytes.Equal(value, other.Data[key]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// ApplySecrets creates or updates the secrets in namespace and deletes the
+// managed secrets not among them. Existing secrets not managed by this tool
+// are left alone and reported as error.
+func (c *Client) ApplySecrets(ctx context.Context, namespace string, secrets []Secret) error {
+	base := "/api/v1/namespaces/" + url.PathEscape(namespace) + "/secrets"
+
+	var list secretList
+
+	err := c.do(ctx, http.MethodGet, base+"?labelSelector="+url.QueryEscape(ManagedByLabel+"="+ManagedBy), nil, &list)
+	if err != nil {
+		return err
+	}
+
+	current := map[string]*Secret{}
+	for i := range list.Items {
+		current[list.Items[i].Metadata.Name] = &list.Items[i]
+	}
+
+	wanted := map[string]bool{}
+
+	for i := range secrets {
+		secret := &secrets[i]
+		name := secret.Metadata.Name
+		wanted[name] = true
+
+		existing, ok := current[name]
+		if ok && existing.equal(secret) {
+			continue
+		}
+
+		logger := log.WithFields(log.Fields{"namespace": namespace, "secret": name})
+
+		if ok {
+			logger.Info("Updating secret")
+
+			secret.Metadata.ResourceVersion = existing.Metadata.ResourceVersion
+			err = c.do(ctx, http.MethodPut, base+"/"+url.PathEscape(name), secret, nil)
+		} else {
+			logger.Info("Creating secret")
+
+			err = c.do(ctx, http.MethodPost, base, secret, nil)
+
+			var apiErr *APIError
+			if errors.As(err, &apiErr) && apiErr.Status == http.StatusConflict {
+				err = errors.New("secret " + namespace + "/" + name + " exists and is not managed by " + ManagedBy)
+			}
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	var stale []string
+	for name := range current {
+		if !wanted[name] {
+			stale = append(stale, name)
+		}
+	}
+
+	sort.Strings(stale)
+
+	for _, name := range stale {
+		log.WithFields(log.Fields{"namespace": namespace, "secret": name}).Info("Deleting secret")
+
+		err := c.do(ctx, http.MethodDelete, base+"/"+url.PathEscape(name), nil, nil)
+		if err != nil && !IsNotFound(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ListSecrets returns the secrets of type in namespace matching the label
+// selector, which may be empty.
+func (c *Client) ListSecrets(ctx context.Context, namespace string, secretType string, selector string) ([]Secret, error) {
+	query := url.Values{}
+	query.Set("fieldSelector", "type="+secretType)
+
+	if selector != "" {
+		query.Set("labelSelector", selector)
+	}
+
+	var list secretList
+
+	err := c.do(ctx, http.MethodGet, "/api/v1/namespaces/"+url.PathEscape(namespace)+"/secrets?"+query.Encode(), nil, &list)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(list.Items, func(i, j int) bool {
+		return list.Items[i].Metadata.Name < list.Items[j].Metadata.Name
+	})
+
+	return list.Items, nil
+}
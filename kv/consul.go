@@ -0,0 +1,116 @@
+package kv
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// DefaultConsulEndpoint is used if no endpoint is set.
+const DefaultConsulEndpoint = "http://127.0.0.1:8500"
+
+func init() {
+	Register("consul", func(opts *Options) (Store, error) {
+		client, scheme, err := opts.httpClient()
+		if err != nil {
+			return nil, err
+		}
+
+		return &consulStore{
+			endpoint: opts.endpoint(DefaultConsulEndpoint, scheme),
+			opts:     opts,
+			client:   client,
+		}, nil
+	})
+}
+
+// consulStore talks to the KV endpoints of the Consul HTTP API.
+type consulStore struct {
+	endpoint string
+	opts     *Options
+	client   *http.Client
+}
+
+type consulPair struct {
+	Key   string
+	Value []byte
+}
+
+func (s *consulStore) do(ctx context.Context, method string, key string, query string, body io.Reader) ([]byte, int, error) {
+	u := s.endpoint + "/v1/kv/" + (&url.URL{Path: key}).EscapedPath()
+	if query != "" {
+		u += "?" + query
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u, body)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if s.opts.Token != "" {
+		req.Header.Set("X-Consul-Token", s.opts.Token)
+	}
+
+	if s.opts.Username != "" {
+		req.SetBasicAuth(s.opts.Username, s.opts.Password)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	defer resp.Body.Close()
+
+	content, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if resp.StatusCode/100 != 2 && resp.StatusCode != http.StatusNotFound {
+		return nil, resp.StatusCode, errors.New("consul returned " + resp.Status + ": " + strings.TrimSpace(string(content)))
+	}
+
+	return content, resp.StatusCode, nil
+}
+
+func (s *consulStore) List(ctx context.Context, prefix string) (map[string]string, error) {
+	content, status, err := s.do(ctx, http.MethodGet, prefix, "recurse=true", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	values := map[string]string{}
+
+	if status == http.StatusNotFound {
+		return values, nil
+	}
+
+	var pairs []consulPair
+
+	err = json.Unmarshal(content, &pairs)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, pair := range pairs {
+		values[pair.Key] = string(pair.Value)
+	}
+
+	return values, nil
+}
+
+func (s *consulStore) Put(ctx context.Context, key string, value string) error {
+	_, _, err := s.do(ctx, http.MethodPut, key, "", strings.NewReader(value))
+	return err
+}
+
+func (s *consulStore) Delete(ctx context.Context, key string) error {
+	_, _, err := s.do(ctx, http.MethodDelete, key, "", nil)
+	return err
+}
@@ -0,0 +1,189 @@
+package kv
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// DefaultEtcdEndpoint is used if no endpoint is set.
+const DefaultEtcdEndpoint = "127.0.0.1:2379"
+
+func init() {
+	Register("etcd", func(opts *Options) (Store, error) {
+		client, scheme, err := opts.httpClient()
+		if err != nil {
+			return nil, err
+		}
+
+		return &etcdStore{
+			endpoint: opts.endpoint(DefaultEtcdEndpoint, scheme),
+			opts:     opts,
+			client:   client,
+		}, nil
+	})
+}
+
+// etcdStore talks to the JSON gateway of the etcd v3 API. Keys and values
+// are base64 encoded by encoding/json as they are []byte.
+type etcdStore struct {
+	endpoint string
+	opts     *Options
+	client   *http.Client
+	token    string
+}
+
+type etcdKeyValue struct {
+	Key   []byte `json:"key"`
+	Value []byte `json:"value,omitempty"`
+}
+
+type etcdRange struct {
+	Key      []byte `json:"key"`
+	RangeEnd []byte `json:"range_end,omitempty"`
+}
+
+type etcdOp struct {
+	RequestPut         *etcdKeyValue `json:"request_put,omitempty"`
+	RequestDeleteRange *etcdRange    `json:"request_delete_range,omitempty"`
+}
+
+type etcdTxn struct {
+	Success []etcdOp `json:"success"`
+}
+
+// prefixEnd returns the end of the key range covering all keys starting with
+// prefix.
+func prefixEnd(prefix string) []byte {
+	end := []byte(prefix)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return end[:i+1]
+		}
+	}
+
+	return []byte{0}
+}
+
+func (s *etcdStore) call(ctx context.Context, path string, request interface{}, response interface{}) error {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	if s.token != "" {
+		req.Header.Set("Authorization", s.token)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	content, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode/100 != 2 {
+		return errors.New("etcd returned " + resp.Status + ": " + strings.TrimSpace(string(content)))
+	}
+
+	if response == nil {
+		return nil
+	}
+
+	return json.Unmarshal(content, response)
+}
+
+// authenticate fetches an auth token once if a username is set.
+func (s *etcdStore) authenticate(ctx context.Context) error {
+	if s.opts.Username == "" || s.token != "" {
+		return nil
+	}
+
+	var response struct {
+		Token string `json:"token"`
+	}
+
+	err := s.call(ctx, "/v3/auth/authenticate", map[string]string{"name": s.opts.Username, "password": s.opts.Password}, &response)
+	if err != nil {
+		return err
+	}
+
+	s.token = response.Token
+
+	return nil
+}
+
+func (s *etcdStore) List(ctx context.Context, prefix string) (map[string]string, error) {
+	if err := s.authenticate(ctx); err != nil {
+		return nil, err
+	}
+
+	var response struct {
+		Kvs []etcdKeyValue `json:"kvs"`
+	}
+
+	err := s.call(ctx, "/v3/kv/range", &etcdRange{Key: []byte(prefix), RangeEnd: prefixEnd(prefix)}, &response)
+	if err != nil {
+		return nil, err
+	}
+
+	values := map[string]string{}
+	for _, kv := range response.Kvs {
+		values[string(kv.Key)] = string(kv.Value)
+	}
+
+	return values, nil
+}
+
+func (s *etcdStore) Put(ctx context.Context, key string, value string) error {
+	return s.Apply(ctx, map[string]string{key: value}, nil)
+}
+
+func (s *etcdStore) Delete(ctx context.Context, key string) error {
+	return s.Apply(ctx, nil, []string{key})
+}
+
+// Apply writes and deletes the keys in a single transaction. etcd limits the
+// operations per transaction, 128 by default, see --max-txn-ops.
+func (s *etcdStore) Apply(ctx context.Context, puts map[string]string, deletes []string) error {
+	if err := s.authenticate(ctx); err != nil {
+		return err
+	}
+
+	var keys []string
+	for key := range puts {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	txn := &etcdTxn{}
+
+	for _, key := range keys {
+		txn.Success = append(txn.Success, etcdOp{RequestPut: &etcdKeyValue{Key: []byte(key), Value: []byte(puts[key])}})
+	}
+
+	for _, key := range deletes {
+		txn.Success = append(txn.Success, etcdOp{RequestDeleteRange: &etcdRange{Key: []byte(key)}})
+	}
+
+	return s.call(ctx, "/v3/kv/txn", txn, nil)
+}
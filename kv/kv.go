@@ -0,0 +1,255 @@
+// Package kv writes the generated config into the key-value stores read by
+// Traefik's KV providers.
+package kv
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Store is a key-value store holding Traefik's dynamic config.
+type Store interface {
+	// List returns all keys below prefix with their values.
+	List(ctx context.Context, prefix string) (map[string]string, error)
+	Put(ctx context.Context, key string, value string) error
+	Delete(ctx context.Context, key string) error
+}
+
+// Options configure the connection to a store.
+type Options struct {
+	Endpoint string
+	Username string
+	Password string
+	// Token is the ACL token of stores supporting them.
+	Token string
+	// CAFile, CertFile and KeyFile configure TLS to the store, CertFile and
+	// KeyFile being the client certificate.
+	CAFile   string
+	CertFile string
+	KeyFile  string
+}
+
+// TLSConfig returns the TLS config for the store, nil if no TLS option is
+// set.
+func (o *Options) TLSConfig() (*tls.Config, error) {
+	if o.CAFile == "" && o.CertFile == "" && o.KeyFile == "" {
+		return nil, nil
+	}
+
+	config := &tls.Config{}
+
+	if o.CAFile != "" {
+		content, err := ioutil.ReadFile(o.CAFile)
+		if err != nil {
+			return nil, err
+		}
+
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(content) {
+			return nil, errors.New("no certificates found in " + o.CAFile)
+		}
+	}
+
+	if o.CertFile != "" || o.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(o.CertFile, o.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	return config, nil
+}
+
+// httpClient returns a client for HTTP based stores and the default scheme
+// of their endpoint.
+func (o *Options) httpClient() (*http.Client, string, error) {
+	config, err := o.TLSConfig()
+	if err != nil {
+		return nil, "", err
+	}
+
+	if config == nil {
+		return &http.Client{Timeout: 30 * time.Second}, "http", nil
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = config
+
+	return &http.Client{Timeout: 30 * time.Second, Transport: transport}, "https", nil
+}
+
+// endpoint returns the endpoint of opts or fallback, with scheme and without
+// trailing slash.
+func (o *Options) endpoint(fallback string, scheme string) string {
+	endpoint := o.Endpoint
+	if endpoint == "" {
+		endpoint = fallback
+	}
+
+	if !strings.Contains(endpoint, "://") {
+		endpoint = scheme + "://" + endpoint
+	}
+
+	return strings.TrimSuffix(endpoint, "/")
+}
+
+// Batch is implemented by stores that can apply all changes of a run in a
+// single transaction.
+type Batch interface {
+	Apply(ctx context.Context, puts map[string]string, deletes []string) error
+}
+
+// Factory creates a store for the given options.
+type Factory func(opts *Options) (Store, error)
+
+var stores = map[string]Factory{}
+
+// Register makes a store available under the given name. It is meant to be
+// called from init functions.
+func Register(name string, factory Factory) {
+	stores[name] = factory
+}
+
+// New returns the store registered under name.
+func New(name string, opts *Options) (Store, error) {
+	factory, ok := stores[name]
+	if !ok {
+		return nil, errors.New("unknown kv store " + name)
+	}
+
+	return factory(opts)
+}
+
+// Names returns the names of all registered stores.
+func Names() []string {
+	var names []string
+	for name := range stores {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// Flatten turns a JSON config into the key layout of Traefik's KV providers,
+// below root. Array elements are keyed by their index.
+func Flatten(root string, config []byte) (map[string]string, error) {
+	decoder := json.NewDecoder(bytes.NewReader(config))
+	decoder.UseNumber()
+
+	var value interface{}
+
+	err := decoder.Decode(&value)
+	if err != nil {
+		return nil, err
+	}
+
+	values := map[string]string{}
+	flatten(strings.TrimSuffix(root, "/"), value, values)
+
+	return values, nil
+}
+
+func flatten(key string, value interface{}, values map[string]string) {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		for name, child := range value {
+			flatten(key+"/"+name, child, values)
+		}
+	case []interface{}:
+		for i, child := range value {
+			flatten(key+"/"+strconv.Itoa(i), child, values)
+		}
+	case nil:
+	default:
+		values[key] = fmt.Sprint(value)
+	}
+}
+
+// Write makes the tls subtree below root match values. Only changed keys
+// are written. Stores implementing Batch get all changes at once, others
+// get the keys not in values deleted last, so Traefik never sees a config
+// with entries missing. Stores implementing io.Closer are closed afterwards
+// and must connect again on their next use.
+func Write(ctx context.Context, store Store, root string, values map[string]string) error {
+	if closer, ok := store.(io.Closer); ok {
+		defer closer.Close()
+	}
+
+	prefix := strings.TrimSuffix(root, "/") + "/tls/"
+
+	current, err := store.List(ctx, prefix)
+	if err != nil {
+		return err
+	}
+
+	puts := map[string]string{}
+	for key, value := range values {
+		if currentValue, ok := current[key]; !ok || currentValue != value {
+			puts[key] = value
+		}
+	}
+
+	var stale []string
+	for key := range current {
+		if _, ok := values[key]; !ok {
+			stale = append(stale, key)
+		}
+	}
+
+	sort.Strings(stale)
+
+	if batch, ok := store.(Batch); ok {
+		if len(puts) == 0 && len(stale) == 0 {
+			return nil
+		}
+
+		log.WithFields(log.Fields{"puts": len(puts), "deletes": len(stale)}).Debug("Applying transaction")
+
+		return batch.Apply(ctx, puts, stale)
+	}
+
+	var keys []string
+	for key := range puts {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		log.WithField("key", key).Debug("Writing key")
+
+		err := store.Put(ctx, key, puts[key])
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, key := range stale {
+		log.WithField("key", key).Debug("Deleting key")
+
+		err := store.Delete(ctx, key)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,327 @@
+package kv
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultRedisEndpoint is used if no endpoint is set.
+const DefaultRedisEndpoint = "127.0.0.1:6379"
+
+func init() {
+	Register("redis", func(opts *Options) (Store, error) {
+		config, err := opts.TLSConfig()
+		if err != nil {
+			return nil, err
+		}
+
+		addr := opts.Endpoint
+		if addr == "" {
+			addr = DefaultRedisEndpoint
+		}
+
+		if strings.HasPrefix(addr, "rediss://") && config == nil {
+			config = &tls.Config{}
+		}
+
+		addr = strings.TrimPrefix(strings.TrimPrefix(addr, "redis://"), "rediss://")
+
+		return &redisStore{addr: strings.TrimSuffix(addr, "/"), tls: config, opts: opts}, nil
+	})
+}
+
+// redisStore speaks just enough of the Redis protocol to maintain the keys
+// of Traefik's Redis provider.
+type redisStore struct {
+	addr string
+	tls  *tls.Config
+	opts *Options
+
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+func (s *redisStore) connect(ctx context.Context) error {
+	if s.conn != nil {
+		return nil
+	}
+
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+
+	var conn net.Conn
+	var err error
+
+	if s.tls != nil {
+		config := s.tls.Clone()
+		if config.ServerName == "" {
+			config.ServerName, _, _ = net.SplitHostPort(s.addr)
+		}
+
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: config}).DialContext(ctx, "tcp", s.addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", s.addr)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	s.conn = conn
+	s.reader = bufio.NewReader(conn)
+
+	if s.opts.Password != "" {
+		args := []string{"AUTH", s.opts.Password}
+		if s.opts.Username != "" {
+			args = []string{"AUTH", s.opts.Username, s.opts.Password}
+		}
+
+		if _, err := s.do(ctx, args...); err != nil {
+			s.close()
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *redisStore) close() {
+	s.conn.Close()
+	s.conn = nil
+}
+
+// Close closes the connection, the next call connects again.
+func (s *redisStore) Close() error {
+	if s.conn != nil {
+		s.close()
+	}
+
+	return nil
+}
+
+// do sends a command and returns its reply.
+func (s *redisStore) do(ctx context.Context, args ...string) (interface{}, error) {
+	if s.conn == nil {
+		return nil, errors.New("redis: connection closed")
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		s.conn.SetDeadline(deadline)
+	} else {
+		s.conn.SetDeadline(time.Now().Add(30 * time.Second))
+	}
+
+	buf := &strings.Builder{}
+	fmt.Fprintf(buf, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(buf, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+
+	if _, err := io.WriteString(s.conn, buf.String()); err != nil {
+		s.close()
+		return nil, err
+	}
+
+	reply, err := s.read()
+	if _, ok := err.(redisError); !ok && err != nil {
+		s.close()
+	}
+
+	return reply, err
+}
+
+type redisError string
+
+func (e redisError) Error() string {
+	return "redis: " + string(e)
+}
+
+// read parses a single reply. Bulk strings are returned as string, arrays as
+// []interface{}, nil replies as nil.
+func (s *redisStore) read() (interface{}, error) {
+	line, err := s.reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, errors.New("redis: empty reply")
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, redisError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		size, err := strconv.Atoi(line[1:])
+		if err != nil || size < 0 {
+			return nil, err
+		}
+
+		data := make([]byte, size+2)
+		if _, err := io.ReadFull(s.reader, data); err != nil {
+			return nil, err
+		}
+
+		return string(data[:size]), nil
+	case '*':
+		count, err := strconv.Atoi(line[1:])
+		if err != nil || count < 0 {
+			return nil, err
+		}
+
+		items := make([]interface{}, count)
+		for i := range items {
+			item, err := s.read()
+			if redisErr, ok := err.(redisError); ok {
+				item = redisErr
+			} else if err != nil {
+				return nil, err
+			}
+
+			items[i] = item
+		}
+
+		return items, nil
+	default:
+		return nil, errors.New("redis: unexpected reply " + line)
+	}
+}
+
+// escapeGlob escapes the glob characters of SCAN MATCH patterns.
+func escapeGlob(value string) string {
+	var buf strings.Builder
+	for _, r := range value {
+		if strings.ContainsRune(`*?[]\`, r) {
+			buf.WriteByte('\\')
+		}
+
+		buf.WriteRune(r)
+	}
+
+	return buf.String()
+}
+
+func (s *redisStore) List(ctx context.Context, prefix string) (map[string]string, error) {
+	if err := s.connect(ctx); err != nil {
+		return nil, err
+	}
+
+	var keys []string
+
+	cursor := "0"
+	for {
+		reply, err := s.do(ctx, "SCAN", cursor, "MATCH", escapeGlob(prefix)+"*", "COUNT", "100")
+		if err != nil {
+			return nil, err
+		}
+
+		items, ok := reply.([]interface{})
+		if !ok || len(items) != 2 {
+			return nil, errors.New("redis: unexpected SCAN reply")
+		}
+
+		cursor, _ = items[0].(string)
+		batch, _ := items[1].([]interface{})
+
+		for _, key := range batch {
+			if key, ok := key.(string); ok {
+				keys = append(keys, key)
+			}
+		}
+
+		if cursor == "0" || cursor == "" {
+			break
+		}
+	}
+
+	values := map[string]string{}
+
+	if len(keys) == 0 {
+		return values, nil
+	}
+
+	reply, err := s.do(ctx, append([]string{"MGET"}, keys...)...)
+	if err != nil {
+		return nil, err
+	}
+
+	items, _ := reply.([]interface{})
+	for i, item := range items {
+		if value, ok := item.(string); ok && i < len(keys) {
+			values[keys[i]] = value
+		}
+	}
+
+	return values, nil
+}
+
+func (s *redisStore) Put(ctx context.Context, key string, value string) error {
+	return s.Apply(ctx, map[string]string{key: value}, nil)
+}
+
+func (s *redisStore) Delete(ctx context.Context, key string) error {
+	return s.Apply(ctx, nil, []string{key})
+}
+
+// Apply writes and deletes the keys in a MULTI/EXEC transaction.
+func (s *redisStore) Apply(ctx context.Context, puts map[string]string, deletes []string) error {
+	if err := s.connect(ctx); err != nil {
+		return err
+	}
+
+	var keys []string
+	for key := range puts {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	if _, err := s.do(ctx, "MULTI"); err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		if _, err := s.do(ctx, "SET", key, puts[key]); err != nil {
+			s.do(ctx, "DISCARD")
+			return err
+		}
+	}
+
+	if len(deletes) > 0 {
+		if _, err := s.do(ctx, append([]string{"DEL"}, deletes...)...); err != nil {
+			s.do(ctx, "DISCARD")
+			return err
+		}
+	}
+
+	reply, err := s.do(ctx, "EXEC")
+	if err != nil {
+		return err
+	}
+
+	if reply == nil {
+		return errors.New("redis: transaction aborted")
+	}
+
+	items, _ := reply.([]interface{})
+	for _, item := range items {
+		if err, ok := item.(error); ok {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/chrisxf/traefik-tls-config-gen/tlsconfig"
+)
+
+type inventoryEntry struct {
+	Path       string    `json:"path"`
+	CommonName string    `json:"commonName"`
+	DNSNames   []string  `json:"dnsNames"`
+	Issuer     string    `json:"issuer"`
+	NotBefore  time.Time `json:"notBefore"`
+	NotAfter   time.Time `json:"notAfter"`
+	KeyType    string    `json:"keyType"`
+	KeySize    int       `json:"keySize"`
+	KeyFile    string    `json:"keyFile,omitempty"`
+}
+
+// publicKeyInfo returns the algorithm and size in bits of the public key of
+// cert.
+func publicKeyInfo(cert *x509.Certificate) (string, int) {
+	switch key := cert.PublicKey.(type) {
+	case *rsa.PublicKey:
+		return "RSA", key.N.BitLen()
+	case *ecdsa.PublicKey:
+		return "ECDSA", key.Curve.Params().BitSize
+	case ed25519.PublicKey:
+		return "Ed25519", 256
+	default:
+		return cert.PublicKeyAlgorithm.String(), 0
+	}
+}
+
+func inventory(result tlsconfig.Result) []inventoryEntry {
+	keyFiles := map[string]string{}
+	for _, pair := range result.Matched {
+		keyFiles[pair.CertPath] = pair.KeyPath
+	}
+
+	var entries []inventoryEntry
+
+	if result.Scanned == nil {
+		return entries
+	}
+
+	for _, pub := range append(result.Scanned.Certs, result.Scanned.Combined...) {
+		keyType, keySize := publicKeyInfo(pub.Cert)
+
+		entries = append(entries, inventoryEntry{
+			Path:       pub.Path,
+			CommonName: pub.Cert.Subject.CommonName,
+			DNSNames:   pub.Cert.DNSNames,
+			Issuer:     pub.Cert.Issuer.CommonName,
+			NotBefore:  pub.Cert.NotBefore,
+			NotAfter:   pub.Cert.NotAfter,
+			KeyType:    keyType,
+			KeySize:    keySize,
+			KeyFile:    keyFiles[pub.Path],
+		})
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Path < entries[j].Path
+	})
+
+	return entries
+}
+
+// printInventory prints a table of all certificates found by a scan, or a
+// JSON array if asJSON is set.
+func printInventory(w io.Writer, result tlsconfig.Result, asJSON bool) error {
+	entries := inventory(result)
+
+	if asJSON {
+		if entries == nil {
+			entries = []inventoryEntry{}
+		}
+
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+
+		return enc.Encode(entries)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "PATH\tSUBJECT\tSANS\tISSUER\tNOT BEFORE\tNOT AFTER\tKEY TYPE\tKEY FILE")
+
+	for _, entry := range entries {
+		keyFile := entry.KeyFile
+		if keyFile == "" {
+			keyFile = "-"
+		}
+
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s %d\t%s\n",
+			entry.Path,
+			entry.CommonName,
+			strings.Join(entry.DNSNames, ","),
+			entry.Issuer,
+			entry.NotBefore.Format(time.RFC3339),
+			entry.NotAfter.Format(time.RFC3339),
+			entry.KeyType,
+			entry.KeySize,
+			keyFile,
+		)
+	}
+
+	return tw.Flush()
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/urfave/cli"
+)
+
+// TestLoadOptionsWithoutRenderFlags checks that the commands without the
+// render flags accept their options.
+func TestLoadOptionsWithoutRenderFlags(t *testing.T) {
+	for _, name := range []string{"list", "check-expiry"} {
+		app := newApp()
+
+		var loadErr error
+		for i := range app.Commands {
+			if app.Commands[i].Name == name {
+				app.Commands[i].Action = func(c *cli.Context) error {
+					_, loadErr = loadOptions(c)
+					return nil
+				}
+			}
+		}
+
+		err := app.Run([]string{app.Name, name, t.TempDir()})
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		if loadErr != nil {
+			t.Errorf("%s: %v", name, loadErr)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package matcher
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/chrisxf/traefik-tls-config-gen/scanner"
+	log "github.com/sirupsen/logrus"
+)
+
+type ChainOptions struct {
+	Dir                string
+	FetchIntermediates bool
+	CacheDir           string
+}
+
+// Intermediates returns all CA certificates contained in the given cert files.
+func Intermediates(certs []scanner.PublicKey) []*x509.Certificate {
+	var intermediates []*x509.Certificate
+
+	for _, pub := range certs {
+		for _, cert := range pub.Chain {
+			if cert.IsCA {
+				intermediates = append(intermediates, cert)
+			}
+		}
+	}
+
+	return intermediates
+}
+
+func findIssuer(cert *x509.Certificate, intermediates []*x509.Certificate) *x509.Certificate {
+	for _, candidate := range intermediates {
+		if bytes.Equal(cert.RawIssuer, candidate.RawSubject) && cert.CheckSignatureFrom(candidate) == nil {
+			return candidate
+		}
+	}
+
+	return nil
+}
+
+// fetchIssuer downloads the issuer of cert from the AIA URLs embedded in it.
+// Responses are cached in cacheDir, keyed by the URL.
+func fetchIssuer(ctx context.Context, cert *x509.Certificate, cacheDir string) *x509.Certificate {
+	client := &http.Client{Timeout: 10 * time.Second}
+
+	for _, url := range cert.IssuingCertificateURL {
+		hash := sha256.Sum256([]byte(url))
+		cachePath := filepath.Join(cacheDir, hex.EncodeToString(hash[:])+".der")
+
+		content, err := ioutil.ReadFile(cachePath)
+		if err != nil {
+			log.WithField("url", url).Info("Fetching intermediate")
+
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+			if err != nil {
+				log.WithFields(log.Fields{"url": url, "error": err}).Warn("Could not fetch intermediate")
+				continue
+			}
+
+			res, err := client.Do(req)
+			if err != nil {
+				log.WithFields(log.Fields{"url": url, "error": err}).Warn("Could not fetch intermediate")
+				continue
+			}
+
+			content, err = ioutil.ReadAll(io.LimitReader(res.Body, 1<<20))
+			res.Body.Close()
+
+			if err != nil || res.StatusCode != http.StatusOK {
+				log.WithFields(log.Fields{"url": url, "status": res.Status}).Warn("Could not fetch intermediate")
+				continue
+			}
+		}
+
+		if bytes.Contains(content, []byte(scanner.PubHeader)) {
+			block, err := scanner.DecodePEMBlock(content, "CERTIFICATE")
+			if err != nil {
+				continue
+			}
+
+			content = block.Bytes
+		}
+
+		issuer, err := x509.ParseCertificate(content)
+		if err != nil || cert.CheckSignatureFrom(issuer) != nil {
+			log.WithField("url", url).Warn("Invalid intermediate")
+			continue
+		}
+
+		if err := os.MkdirAll(cacheDir, 0755); err == nil {
+			ioutil.WriteFile(cachePath, content, 0644)
+		}
+
+		return issuer
+	}
+
+	return nil
+}
+
+// CompleteChains looks up the intermediates missing from the cert file of
+// every pair. If a chain dir is set, a full chain file in it is planned and
+// the pair is pointed at it, the files are returned for the caller to write.
+// Otherwise incomplete chains are only reported.
+func CompleteChains(ctx context.Context, pairs []KeyPair, intermediates []*x509.Certificate, opts *ChainOptions) ([]KeyPair, []scanner.ManagedFile, error) {
+	var files []scanner.ManagedFile
+
+	for i, pair := range pairs {
+		if err := ctx.Err(); err != nil {
+			return nil, nil, err
+		}
+
+		if len(pair.Chain) == 0 {
+			continue
+		}
+
+		var missing []*x509.Certificate
+
+		last := pair.Chain[len(pair.Chain)-1]
+
+		for len(pair.Chain)+len(missing) < 10 && !bytes.Equal(last.RawIssuer, last.RawSubject) {
+			issuer := findIssuer(last, intermediates)
+			if issuer == nil && opts.FetchIntermediates {
+				issuer = fetchIssuer(ctx, last, opts.CacheDir)
+			}
+
+			if issuer == nil || bytes.Equal(issuer.RawIssuer, issuer.RawSubject) {
+				break
+			}
+
+			missing = append(missing, issuer)
+			last = issuer
+		}
+
+		if len(missing) == 0 {
+			continue
+		}
+
+		if opts.Dir == "" {
+			scanner.CertLogger(pair.CertPath, pair.Cert).WithField("missing", len(missing)).Warn("Incomplete chain, intermediates found elsewhere")
+			continue
+		}
+
+		buf := &bytes.Buffer{}
+
+		for _, cert := range append(pair.Chain, missing...) {
+			pem.Encode(buf, &pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+		}
+
+		certPath := filepath.Join(opts.Dir, scanner.ManagedFileName(pair.CertPath, pair.Cert.Raw)+"-fullchain.pem")
+
+		files = append(files, scanner.ManagedFile{Path: certPath, Content: buf.Bytes(), Perm: 0644})
+
+		scanner.CertLogger(pair.CertPath, pair.Cert).WithField("fullchainFile", certPath).Info("Full chain")
+
+		pairs[i].Chain = append(pair.Chain, missing...)
+		pairs[i].CertPath = certPath
+	}
+
+	return pairs, files, nil
+}
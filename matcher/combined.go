@@ -0,0 +1,46 @@
+package matcher
+
+import (
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/chrisxf/traefik-tls-config-gen/scanner"
+	log "github.com/sirupsen/logrus"
+)
+
+// SplitCombined plans separate files inside dir for the certificates and the
+// private key of every combined file and points the pair at them. The files
+// are returned for the caller to write.
+func SplitCombined(pairs []KeyPair, dir string) ([]KeyPair, []scanner.ManagedFile, error) {
+	var files []scanner.ManagedFile
+
+	for i, pair := range pairs {
+		if pair.CertPath != pair.KeyPath {
+			continue
+		}
+
+		content, err := ioutil.ReadFile(pair.CertPath)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		certs, keys := scanner.SplitPEM(content)
+
+		name := scanner.ManagedFileName(pair.CertPath, pair.Cert.Raw)
+
+		certPath := filepath.Join(dir, name+".crt")
+		keyPath := filepath.Join(dir, name+".key")
+
+		files = append(files,
+			scanner.ManagedFile{Path: certPath, Content: certs, Perm: 0644},
+			scanner.ManagedFile{Path: keyPath, Content: keys, Perm: 0600},
+		)
+
+		log.WithFields(log.Fields{"path": pair.CertPath, "certFile": certPath, "keyFile": keyPath}).Info("Split combined file")
+
+		pairs[i].CertPath = certPath
+		pairs[i].KeyPath = keyPath
+	}
+
+	return pairs, files, nil
+}
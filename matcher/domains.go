@@ -0,0 +1,54 @@
+package matcher
+
+import (
+	"crypto/x509"
+	"path"
+	"strings"
+
+	"github.com/chrisxf/traefik-tls-config-gen/scanner"
+)
+
+// Domains returns the SANs of cert, or its common name if it has none.
+func Domains(cert *x509.Certificate) []string {
+	if len(cert.DNSNames) > 0 {
+		return cert.DNSNames
+	}
+
+	return []string{cert.Subject.CommonName}
+}
+
+// MatchDomains reports whether any domain of cert matches one of the glob
+// patterns, ignoring case.
+func MatchDomains(patterns []string, cert *x509.Certificate) bool {
+	for _, pattern := range patterns {
+		for _, domain := range Domains(cert) {
+			if ok, _ := path.Match(strings.ToLower(pattern), strings.ToLower(domain)); ok {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// FilterDomains keeps the pairs with a domain matching one of the only
+// patterns, if any are given, and drops those with a domain matching one of
+// the skip patterns.
+func FilterDomains(pairs []KeyPair, only []string, skip []string) []KeyPair {
+	if len(only) == 0 && len(skip) == 0 {
+		return pairs
+	}
+
+	var filtered []KeyPair
+
+	for _, pair := range pairs {
+		if (len(only) > 0 && !MatchDomains(only, pair.Cert)) || MatchDomains(skip, pair.Cert) {
+			scanner.CertLogger(pair.CertPath, pair.Cert).Debug("Skipped by domain filter")
+			continue
+		}
+
+		filtered = append(filtered, pair)
+	}
+
+	return filtered
+}
@@ -0,0 +1,100 @@
+package matcher
+
+import (
+	"crypto/sha256"
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/chrisxf/traefik-tls-config-gen/scanner"
+)
+
+// Policies for pairs covering the same set of domains.
+const (
+	// PreferNewest keeps the certificate issued last.
+	PreferNewest = "newest"
+	// PreferLongestValidity keeps the certificate expiring last.
+	PreferLongestValidity = "longest-validity"
+	// PreferAll keeps all pairs.
+	PreferAll = "all"
+)
+
+// Dedupe drops pairs whose certificate is identical to the one of another
+// pair, e.g. the same file reached through a symlink or a copy. The pair with
+// the longest chain is kept, so fullchain.pem wins over cert.pem, and the
+// first one among chains of the same length. The order of the pairs is kept.
+func Dedupe(pairs []KeyPair) []KeyPair {
+	best := map[[sha256.Size]byte]int{}
+	for i, pair := range pairs {
+		fingerprint := sha256.Sum256(pair.Cert.Raw)
+		if j, ok := best[fingerprint]; !ok || len(pair.Chain) > len(pairs[j].Chain) {
+			best[fingerprint] = i
+		}
+	}
+
+	var deduped []KeyPair
+
+	for i, pair := range pairs {
+		kept := best[sha256.Sum256(pair.Cert.Raw)]
+		if kept != i {
+			scanner.CertLogger(pair.CertPath, pair.Cert).WithField("kept", pairs[kept].CertPath).Info("Dropping identical certificate")
+			continue
+		}
+
+		deduped = append(deduped, pair)
+	}
+
+	return deduped
+}
+
+// domainSet returns a key identifying the domains of a certificate,
+// regardless of their order and case.
+func domainSet(pair KeyPair) string {
+	var domains []string
+	for _, domain := range Domains(pair.Cert) {
+		domains = append(domains, strings.ToLower(domain))
+	}
+
+	sort.Strings(domains)
+
+	return strings.Join(domains, ",")
+}
+
+// ResolveDuplicates keeps a single pair for every set of domains according to
+// policy, an empty policy means PreferNewest. The order of the pairs is kept.
+func ResolveDuplicates(pairs []KeyPair, policy string) ([]KeyPair, error) {
+	var better func(a, b KeyPair) bool
+
+	switch policy {
+	case PreferAll:
+		return pairs, nil
+	case PreferNewest, "":
+		better = func(a, b KeyPair) bool { return a.Cert.NotBefore.After(b.Cert.NotBefore) }
+	case PreferLongestValidity:
+		better = func(a, b KeyPair) bool { return a.Cert.NotAfter.After(b.Cert.NotAfter) }
+	default:
+		return nil, errors.New("unknown duplicate policy " + policy)
+	}
+
+	best := map[string]int{}
+	for i, pair := range pairs {
+		key := domainSet(pair)
+		if j, ok := best[key]; !ok || better(pair, pairs[j]) {
+			best[key] = i
+		}
+	}
+
+	var resolved []KeyPair
+
+	for i, pair := range pairs {
+		kept := pairs[best[domainSet(pair)]]
+		if best[domainSet(pair)] != i {
+			scanner.CertLogger(pair.CertPath, pair.Cert).WithField("kept", kept.CertPath).Info("Dropping duplicate certificate")
+			continue
+		}
+
+		resolved = append(resolved, pair)
+	}
+
+	return resolved, nil
+}
@@ -0,0 +1,94 @@
+// Package matcher pairs certificates with their private keys and completes
+// their chains.
+package matcher
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"sort"
+
+	"github.com/chrisxf/traefik-tls-config-gen/scanner"
+	log "github.com/sirupsen/logrus"
+)
+
+type KeyPair struct {
+	Cert     *x509.Certificate
+	Chain    []*x509.Certificate
+	CertPath string
+	KeyPath  string
+}
+
+// indexKeys maps the fingerprints of the public keys to the private keys
+// having them.
+func indexKeys(keys []scanner.PublicKey) map[[sha256.Size]byte][]scanner.PublicKey {
+	index := map[[sha256.Size]byte][]scanner.PublicKey{}
+
+	for _, key := range keys {
+		fingerprint, err := scanner.KeyFingerprint(key.Key)
+		if err != nil {
+			log.WithFields(log.Fields{"path": key.Path, "error": err}).Warn("Could not fingerprint private key")
+			continue
+		}
+
+		index[fingerprint] = append(index[fingerprint], key)
+	}
+
+	return index
+}
+
+// Match pairs the certificates of a scan with their private keys. Combined
+// files are pairs on their own. The pairs are returned sorted.
+func Match(result *scanner.Result) []KeyPair {
+	var pairs []KeyPair
+
+	index := indexKeys(result.Keys)
+
+	for _, pub := range result.Certs {
+		fingerprint, err := scanner.KeyFingerprint(pub.Key)
+		if err != nil {
+			continue
+		}
+
+		for _, privateKey := range index[fingerprint] {
+			if !scanner.PublicKeysEqual(pub.Key, privateKey.Key) {
+				continue
+			}
+
+			scanner.CertLogger(pub.Path, pub.Cert).WithField("keyFile", privateKey.Path).Info("Valid pair")
+
+			pairs = append(pairs, KeyPair{
+				Cert:     pub.Cert,
+				Chain:    pub.Chain,
+				CertPath: pub.Path,
+				KeyPath:  privateKey.Path,
+			})
+
+			break
+		}
+	}
+
+	for _, combined := range result.Combined {
+		pairs = append(pairs, KeyPair{
+			Cert:     combined.Cert,
+			Chain:    combined.Chain,
+			CertPath: combined.Path,
+			KeyPath:  combined.KeyPath,
+		})
+	}
+
+	Sort(pairs)
+
+	return pairs
+}
+
+// Sort orders pairs by the common name of their certificate and then by cert
+// path, so the generated config is stable across runs.
+func Sort(pairs []KeyPair) {
+	sort.SliceStable(pairs, func(i, j int) bool {
+		if pairs[i].Cert.Subject.CommonName != pairs[j].Cert.Subject.CommonName {
+			return pairs[i].Cert.Subject.CommonName < pairs[j].Cert.Subject.CommonName
+		}
+
+		return pairs[i].CertPath < pairs[j].CertPath
+	})
+}
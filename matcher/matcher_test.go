@@ -0,0 +1,115 @@
+package matcher
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"math/big"
+	mathrand "math/rand"
+	"testing"
+	"time"
+
+	"github.com/chrisxf/traefik-tls-config-gen/scanner"
+	log "github.com/sirupsen/logrus"
+)
+
+// benchmarkPairs is the number of generated cert/key pairs of BenchmarkMatch.
+const benchmarkPairs = 3000
+
+// generateScan returns a scan result with n certificates and their keys, in
+// shuffled order so the matching cannot rely on the file order.
+func generateScan(b *testing.B, n int) *scanner.Result {
+	result := &scanner.Result{}
+
+	for i := 0; i < n; i++ {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		domain := fmt.Sprintf("host%d.example.com", i)
+
+		result.Certs = append(result.Certs, scanner.PublicKey{
+			Path: "/certs/" + domain + "/cert.pem",
+			Key:  &key.PublicKey,
+			Cert: &x509.Certificate{Subject: pkix.Name{CommonName: domain}},
+		})
+
+		result.Keys = append(result.Keys, scanner.PublicKey{
+			Path: "/certs/" + domain + "/privkey.pem",
+			Key:  &key.PublicKey,
+		})
+	}
+
+	shuffle := mathrand.New(mathrand.NewSource(1))
+	shuffle.Shuffle(len(result.Keys), func(i, j int) {
+		result.Keys[i], result.Keys[j] = result.Keys[j], result.Keys[i]
+	})
+
+	return result
+}
+
+func BenchmarkMatch(b *testing.B) {
+	level := log.GetLevel()
+	log.SetLevel(log.ErrorLevel)
+	defer log.SetLevel(level)
+
+	result := generateScan(b, benchmarkPairs)
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if pairs := Match(result); len(pairs) != benchmarkPairs {
+			b.Fatalf("got %d pairs, want %d", len(pairs), benchmarkPairs)
+		}
+	}
+}
+
+// selfSigned returns a self-signed certificate for domain.
+func selfSigned(t *testing.T, domain string) *x509.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: domain},
+		DNSNames:     []string{domain},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return cert
+}
+
+func TestDedupeKeepsLongestChain(t *testing.T) {
+	cert := selfSigned(t, "example.com")
+	intermediate := selfSigned(t, "Intermediate CA")
+
+	pairs := Dedupe([]KeyPair{
+		{Cert: cert, CertPath: "/certs/example.com/cert.pem", KeyPath: "/certs/example.com/privkey.pem"},
+		{Cert: cert, Chain: []*x509.Certificate{intermediate}, CertPath: "/certs/example.com/fullchain.pem", KeyPath: "/certs/example.com/privkey.pem"},
+	})
+
+	if len(pairs) != 1 {
+		t.Fatalf("got %d pairs, want 1", len(pairs))
+	}
+
+	if pairs[0].CertPath != "/certs/example.com/fullchain.pem" {
+		t.Errorf("kept %s, want /certs/example.com/fullchain.pem", pairs[0].CertPath)
+	}
+}
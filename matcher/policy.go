@@ -0,0 +1,102 @@
+package matcher
+
+import (
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/chrisxf/traefik-tls-config-gen/scanner"
+)
+
+// Actions for pairs violating the crypto policy.
+const (
+	// PolicyWarn only logs the pairs.
+	PolicyWarn = "warn"
+	// PolicyExclude drops the pairs.
+	PolicyExclude = "exclude"
+	// PolicyFail fails the run.
+	PolicyFail = "fail"
+)
+
+var weakSignatures = map[x509.SignatureAlgorithm]bool{
+	x509.MD2WithRSA:    true,
+	x509.MD5WithRSA:    true,
+	x509.SHA1WithRSA:   true,
+	x509.DSAWithSHA1:   true,
+	x509.ECDSAWithSHA1: true,
+}
+
+// PolicyViolations returns the weaknesses of cert: RSA keys below 2048 bits,
+// EC keys below 256 bits, MD5 or SHA-1 signatures and a validity longer than
+// maxValidity, if it is not zero.
+func PolicyViolations(cert *x509.Certificate, maxValidity time.Duration) []string {
+	var violations []string
+
+	switch key := cert.PublicKey.(type) {
+	case *rsa.PublicKey:
+		if bits := key.N.BitLen(); bits < 2048 {
+			violations = append(violations, "RSA key with "+strconv.Itoa(bits)+" bits")
+		}
+	case *ecdsa.PublicKey:
+		if bits := key.Curve.Params().BitSize; bits < 256 {
+			violations = append(violations, "EC key with "+strconv.Itoa(bits)+" bits")
+		}
+	}
+
+	if weakSignatures[cert.SignatureAlgorithm] {
+		violations = append(violations, cert.SignatureAlgorithm.String()+" signature")
+	}
+
+	if validity := cert.NotAfter.Sub(cert.NotBefore); maxValidity > 0 && validity > maxValidity {
+		violations = append(violations, "validity of "+strconv.Itoa(int(validity.Hours()/24))+" days")
+	}
+
+	return violations
+}
+
+// CheckPolicy logs the pairs violating the crypto policy and applies action
+// to them, an empty action means PolicyWarn. With PolicyFail an error is
+// returned if any pair violates the policy.
+func CheckPolicy(pairs []KeyPair, action string, maxValidity time.Duration) ([]KeyPair, error) {
+	switch action {
+	case PolicyWarn, PolicyExclude, PolicyFail, "":
+	default:
+		return nil, errors.New("unknown policy action " + action)
+	}
+
+	var checked []KeyPair
+
+	failed := 0
+
+	for _, pair := range pairs {
+		violations := PolicyViolations(pair.Cert, maxValidity)
+		if len(violations) == 0 {
+			checked = append(checked, pair)
+			continue
+		}
+
+		logger := scanner.CertLogger(pair.CertPath, pair.Cert).WithField("violations", violations)
+
+		switch action {
+		case PolicyExclude:
+			logger.Warn("Certificate violates crypto policy, skipping pair")
+			continue
+		case PolicyFail:
+			logger.Error("Certificate violates crypto policy")
+			failed++
+		default:
+			logger.Warn("Certificate violates crypto policy")
+		}
+
+		checked = append(checked, pair)
+	}
+
+	if failed > 0 {
+		return nil, errors.New(strconv.Itoa(failed) + " certificates violate the crypto policy")
+	}
+
+	return checked, nil
+}
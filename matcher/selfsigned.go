@@ -0,0 +1,58 @@
+package matcher
+
+import (
+	"bytes"
+	"crypto/x509"
+	"errors"
+
+	"github.com/chrisxf/traefik-tls-config-gen/scanner"
+)
+
+// Handling of self-signed leaf certificates.
+const (
+	// SelfSignedInclude keeps them silently.
+	SelfSignedInclude = "include"
+	// SelfSignedWarn keeps and logs them.
+	SelfSignedWarn = "warn"
+	// SelfSignedExclude drops them.
+	SelfSignedExclude = "exclude"
+)
+
+// IsSelfSigned reports whether cert is signed by its own key.
+func IsSelfSigned(cert *x509.Certificate) bool {
+	return bytes.Equal(cert.RawIssuer, cert.RawSubject) &&
+		cert.CheckSignature(cert.SignatureAlgorithm, cert.RawTBSCertificate, cert.Signature) == nil
+}
+
+// FilterSelfSigned applies handling to the pairs with a self-signed
+// certificate, an empty handling means SelfSignedInclude.
+func FilterSelfSigned(pairs []KeyPair, handling string) ([]KeyPair, error) {
+	switch handling {
+	case SelfSignedInclude, "":
+		return pairs, nil
+	case SelfSignedWarn, SelfSignedExclude:
+	default:
+		return nil, errors.New("unknown self-signed handling " + handling)
+	}
+
+	var filtered []KeyPair
+
+	for _, pair := range pairs {
+		if !IsSelfSigned(pair.Cert) {
+			filtered = append(filtered, pair)
+			continue
+		}
+
+		logger := scanner.CertLogger(pair.CertPath, pair.Cert)
+
+		if handling == SelfSignedExclude {
+			logger.Warn("Self-signed certificate, skipping pair")
+			continue
+		}
+
+		logger.Warn("Self-signed certificate")
+		filtered = append(filtered, pair)
+	}
+
+	return filtered, nil
+}
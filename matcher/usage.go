@@ -0,0 +1,64 @@
+package matcher
+
+import (
+	"crypto/rsa"
+	"crypto/x509"
+
+	"github.com/chrisxf/traefik-tls-config-gen/scanner"
+)
+
+// UsageProblem returns why cert cannot be used by a TLS server, an empty
+// string if it can. Certificates without extended key usage or key usage
+// extension are not restricted.
+func UsageProblem(cert *x509.Certificate) string {
+	if len(cert.ExtKeyUsage) > 0 || len(cert.UnknownExtKeyUsage) > 0 {
+		serverAuth := false
+
+		for _, usage := range cert.ExtKeyUsage {
+			if usage == x509.ExtKeyUsageServerAuth || usage == x509.ExtKeyUsageAny {
+				serverAuth = true
+			}
+		}
+
+		if !serverAuth {
+			return "extended key usage lacks serverAuth"
+		}
+	}
+
+	if cert.KeyUsage == 0 || cert.KeyUsage&x509.KeyUsageDigitalSignature != 0 {
+		return ""
+	}
+
+	// RSA key exchange only needs keyEncipherment.
+	if _, ok := cert.PublicKey.(*rsa.PublicKey); ok && cert.KeyUsage&x509.KeyUsageKeyEncipherment != 0 {
+		return ""
+	}
+
+	return "key usage lacks digitalSignature"
+}
+
+// CheckUsage logs the pairs whose certificate is not meant for TLS servers,
+// and drops them if require is set.
+func CheckUsage(pairs []KeyPair, require bool) []KeyPair {
+	var checked []KeyPair
+
+	for _, pair := range pairs {
+		problem := UsageProblem(pair.Cert)
+		if problem == "" {
+			checked = append(checked, pair)
+			continue
+		}
+
+		logger := scanner.CertLogger(pair.CertPath, pair.Cert).WithField("reason", problem)
+
+		if require {
+			logger.Warn("Certificate not usable for TLS servers, skipping pair")
+			continue
+		}
+
+		logger.Warn("Certificate not usable for TLS servers")
+		checked = append(checked, pair)
+	}
+
+	return checked
+}
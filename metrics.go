@@ -0,0 +1,112 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/chrisxf/traefik-tls-config-gen/tlsconfig"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+type Metrics struct {
+	registry         *prometheus.Registry
+	certsTotal       prometheus.Gauge
+	pairsTotal       prometheus.Gauge
+	orphanKeysTotal  prometheus.Gauge
+	scanErrorsTotal  prometheus.Gauge
+	certNotAfter     *prometheus.GaugeVec
+	duration         prometheus.Gauge
+	lastRunTimestamp prometheus.Gauge
+	errorsTotal      prometheus.Counter
+}
+
+func newMetrics() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		certsTotal: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "tls_certs_total",
+			Help: "Number of certificates found in the last run.",
+		}),
+		pairsTotal: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "tls_pairs_total",
+			Help: "Number of valid certificate/key pairs found in the last run.",
+		}),
+		orphanKeysTotal: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "tls_orphan_keys_total",
+			Help: "Number of private keys without a matching certificate in the last run.",
+		}),
+		scanErrorsTotal: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "tls_scan_errors_total",
+			Help: "Number of files that could not be parsed in the last run.",
+		}),
+		certNotAfter: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "tls_cert_not_after_timestamp",
+			Help: "Expiry of the certificate serving a domain as unix timestamp.",
+		}, []string{"domain", "path"}),
+		duration: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "tls_generation_duration_seconds",
+			Help: "Duration of the last config generation.",
+		}),
+		lastRunTimestamp: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "tls_generation_last_run_timestamp",
+			Help: "Time of the last config generation as unix timestamp.",
+		}),
+		errorsTotal: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "tls_generation_errors_total",
+			Help: "Number of failed config generations.",
+		}),
+	}
+
+	m.registry.MustRegister(
+		m.certsTotal,
+		m.pairsTotal,
+		m.orphanKeysTotal,
+		m.scanErrorsTotal,
+		m.certNotAfter,
+		m.duration,
+		m.lastRunTimestamp,
+		m.errorsTotal,
+	)
+
+	return m
+}
+
+func (m *Metrics) update(result tlsconfig.Result, err error) {
+	m.lastRunTimestamp.Set(float64(time.Now().Unix()))
+
+	if err != nil {
+		m.errorsTotal.Inc()
+		return
+	}
+
+	m.certsTotal.Set(float64(result.Certs))
+	m.pairsTotal.Set(float64(len(result.Pairs)))
+	m.orphanKeysTotal.Set(float64(result.OrphanKeys))
+	m.scanErrorsTotal.Set(float64(result.ScanErrors))
+	m.duration.Set(result.Duration.Seconds())
+
+	m.certNotAfter.Reset()
+
+	for _, pair := range result.Pairs {
+		domains := pair.Cert.DNSNames
+		if len(domains) == 0 {
+			domains = []string{pair.Cert.Subject.CommonName}
+		}
+
+		for _, domain := range domains {
+			m.certNotAfter.WithLabelValues(domain, pair.CertPath).Set(float64(pair.Cert.NotAfter.Unix()))
+		}
+	}
+}
+
+func (m *Metrics) serve(addr string) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{}))
+
+	return http.ListenAndServe(addr, mux)
+}
+
+func (m *Metrics) writeTextfile(path string) error {
+	return prometheus.WriteToTextfile(path, m.registry)
+}
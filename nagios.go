@@ -0,0 +1,94 @@
+package main
+
+import (
+	"crypto/x509"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/chrisxf/traefik-tls-config-gen/scanner"
+	"github.com/chrisxf/traefik-tls-config-gen/tlsconfig"
+)
+
+// Exit codes of Nagios plugins.
+const (
+	nagiosOK       = 0
+	nagiosWarning  = 1
+	nagiosCritical = 2
+	nagiosUnknown  = 3
+)
+
+var nagiosStates = []string{"OK", "WARNING", "CRITICAL", "UNKNOWN"}
+
+type expiringCert struct {
+	path string
+	cert *x509.Certificate
+	days int
+}
+
+// nagiosStatus returns the plugin output line and exit code for the
+// certificates of all pairs and the expired certificates dropped by the scan.
+func nagiosStatus(result tlsconfig.Result, warnDays int, critDays int) (string, int) {
+	var certs []expiringCert
+
+	now := time.Now()
+
+	add := func(path string, cert *x509.Certificate) {
+		days := int(cert.NotAfter.Sub(now).Hours() / 24)
+		certs = append(certs, expiringCert{path: path, cert: cert, days: days})
+	}
+
+	for _, pair := range result.Pairs {
+		add(pair.CertPath, pair.Cert)
+	}
+
+	if result.Scanned != nil {
+		for _, fileErr := range result.Scanned.Errors {
+			if fileErr.Err == scanner.ErrExpired && fileErr.Cert != nil {
+				add(fileErr.Path, fileErr.Cert)
+			}
+		}
+	}
+
+	if len(certs) == 0 {
+		return "TLS UNKNOWN - no certificates found", nagiosUnknown
+	}
+
+	sort.SliceStable(certs, func(i, j int) bool {
+		return certs[i].days < certs[j].days
+	})
+
+	var critical, warning []string
+	minDays := certs[0].days
+
+	for _, c := range certs {
+		name := c.cert.Subject.CommonName
+		if name == "" {
+			name = c.path
+		}
+
+		switch {
+		case c.days < critDays:
+			critical = append(critical, fmt.Sprintf("%s (%d days)", name, c.days))
+		case c.days < warnDays:
+			warning = append(warning, fmt.Sprintf("%s (%d days)", name, c.days))
+		}
+	}
+
+	code := nagiosOK
+	summary := fmt.Sprintf("%d certificates valid for at least %d days", len(certs), minDays)
+
+	if len(critical) > 0 {
+		code = nagiosCritical
+		summary = "expiring: " + strings.Join(append(critical, warning...), ", ")
+	} else if len(warning) > 0 {
+		code = nagiosWarning
+		summary = "expiring: " + strings.Join(warning, ", ")
+	}
+
+	perfdata := fmt.Sprintf("certs=%d critical=%d warning=%d min_days=%d;%d;%d",
+		len(certs), len(critical), len(warning), minDays, warnDays, critDays)
+
+	return "TLS " + nagiosStates[code] + " - " + summary + " | " + perfdata, code
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/chrisxf/traefik-tls-config-gen/tlsconfig"
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli"
+)
+
+const (
+	eventChanged  = "changed"
+	eventExpiring = "expiring"
+	eventOrphans  = "orphans"
+	eventDropped  = "dropped"
+	eventRefused  = "refused"
+)
+
+type notifyCert struct {
+	Path       string    `json:"path"`
+	CommonName string    `json:"commonName"`
+	NotAfter   time.Time `json:"notAfter"`
+	Days       int       `json:"days"`
+}
+
+// notification is the default webhook payload and the data passed to
+// payload templates.
+type notification struct {
+	Events     []string     `json:"events"`
+	Text       string       `json:"text"`
+	Out        string       `json:"out"`
+	Pairs      int          `json:"pairs"`
+	Expiring   []notifyCert `json:"expiring"`
+	OrphanKeys []string     `json:"orphanKeys"`
+	// DroppedDomains are the domains no longer in the config.
+	DroppedDomains []string `json:"droppedDomains"`
+}
+
+func (msg *notification) has(event string) bool {
+	for _, e := range msg.Events {
+		if e == event {
+			return true
+		}
+	}
+
+	return false
+}
+
+// notifier sends notifications about runs that changed the config or found
+// problems. Problems are only reported again once they change, so watch mode
+// does not repeat the same notification every interval.
+type notifier struct {
+	webhook  string
+	template *template.Template
+	smtp     *smtpSettings
+	warnDays int
+	client   *http.Client
+	expiring string
+	orphans  string
+}
+
+// newNotifier returns nil if no notification target is set.
+func newNotifier(c *cli.Context) (*notifier, error) {
+	smtp, err := loadSMTPSettings(c)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.String("notify-webhook") == "" && smtp == nil {
+		return nil, nil
+	}
+
+	n := &notifier{
+		webhook:  c.String("notify-webhook"),
+		smtp:     smtp,
+		warnDays: c.Int("warn-days"),
+		client:   &http.Client{Timeout: 10 * time.Second},
+	}
+
+	if path := c.String("notify-template"); path != "" {
+		tmpl, err := template.New("").Funcs(template.FuncMap{
+			"json": func(value interface{}) (string, error) {
+				content, err := json.Marshal(value)
+				return string(content), err
+			},
+			"join": strings.Join,
+		}).ParseFiles(path)
+		if err != nil {
+			return nil, err
+		}
+
+		n.template = tmpl.Lookup(filepath.Base(path))
+	}
+
+	return n, nil
+}
+
+// build returns the notification for result, or nil if there is nothing new
+// to report.
+func (n *notifier) build(out string, result tlsconfig.Result) *notification {
+	msg := &notification{
+		Out:            out,
+		Pairs:          len(result.Pairs),
+		Expiring:       []notifyCert{},
+		OrphanKeys:     []string{},
+		DroppedDomains: []string{},
+	}
+
+	var expiring, orphans []string
+	var lines []string
+
+	if n.warnDays > 0 {
+		for _, pair := range result.Pairs {
+			remaining := time.Until(pair.Cert.NotAfter)
+			if remaining >= time.Duration(n.warnDays)*24*time.Hour {
+				continue
+			}
+
+			days := int(remaining.Hours() / 24)
+
+			msg.Expiring = append(msg.Expiring, notifyCert{
+				Path:       pair.CertPath,
+				CommonName: pair.Cert.Subject.CommonName,
+				NotAfter:   pair.Cert.NotAfter.UTC(),
+				Days:       days,
+			})
+
+			expiring = append(expiring, pair.CertPath)
+			lines = append(lines, fmt.Sprintf("%s expires in %d days (%s)", pair.Cert.Subject.CommonName, days, pair.CertPath))
+		}
+	}
+
+	for _, orphan := range result.Orphans {
+		if orphan.Reason != "no matching certificate" {
+			continue
+		}
+
+		msg.OrphanKeys = append(msg.OrphanKeys, orphan.Path)
+		orphans = append(orphans, orphan.Path)
+		lines = append(lines, "Private key without certificate: "+orphan.Path)
+	}
+
+	for _, domain := range result.DroppedDomains {
+		msg.DroppedDomains = append(msg.DroppedDomains, domain)
+		lines = append(lines, "Domain no longer in config: "+domain)
+	}
+
+	if result.Changed {
+		msg.Events = append(msg.Events, eventChanged)
+		lines = append([]string{fmt.Sprintf("TLS config %s updated with %d certificates", out, len(result.Pairs))}, lines...)
+	}
+
+	if key := strings.Join(expiring, "\n"); key != n.expiring {
+		n.expiring = key
+
+		if len(expiring) > 0 {
+			msg.Events = append(msg.Events, eventExpiring)
+		}
+	}
+
+	if key := strings.Join(orphans, "\n"); key != n.orphans {
+		n.orphans = key
+
+		if len(orphans) > 0 {
+			msg.Events = append(msg.Events, eventOrphans)
+		}
+	}
+
+	if len(msg.DroppedDomains) > 0 {
+		msg.Events = append(msg.Events, eventDropped)
+	}
+
+	if len(msg.Events) == 0 {
+		return nil
+	}
+
+	msg.Text = strings.Join(lines, "\n")
+
+	return msg
+}
+
+// notify posts the notification for result to the webhook and mails a
+// digest of expiring certificates and dropped domains, if configured.
+func (n *notifier) notify(ctx context.Context, out string, result tlsconfig.Result) error {
+	msg := n.build(out, result)
+	if msg == nil {
+		return nil
+	}
+
+	return n.send(ctx, msg)
+}
+
+// alert notifies about a config that was not written because of err.
+func (n *notifier) alert(ctx context.Context, out string, err error) error {
+	return n.send(ctx, &notification{
+		Events:         []string{eventRefused},
+		Text:           "TLS config " + out + " not written: " + err.Error(),
+		Out:            out,
+		Expiring:       []notifyCert{},
+		OrphanKeys:     []string{},
+		DroppedDomains: []string{},
+	})
+}
+
+// send posts msg to the webhook and mails it for the events worth a mail.
+func (n *notifier) send(ctx context.Context, msg *notification) error {
+	var errs []string
+
+	if n.webhook != "" {
+		if err := n.postWebhook(ctx, msg); err != nil {
+			errs = append(errs, "webhook: "+err.Error())
+		}
+	}
+
+	if n.smtp != nil && (msg.has(eventExpiring) || msg.has(eventDropped) || msg.has(eventRefused)) {
+		if err := n.smtp.send(msg); err != nil {
+			errs = append(errs, "smtp: "+err.Error())
+		}
+	}
+
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+
+	return nil
+}
+
+func (n *notifier) postWebhook(ctx context.Context, msg *notification) error {
+	var body []byte
+	var err error
+
+	if n.template != nil {
+		buf := &bytes.Buffer{}
+		err = n.template.Execute(buf, msg)
+		body = buf.Bytes()
+	} else {
+		body, err = json.Marshal(msg)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.webhook, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	log.WithField("events", strings.Join(msg.Events, ",")).Info("Sending webhook notification")
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return errors.New("webhook returned " + resp.Status)
+	}
+
+	return nil
+}
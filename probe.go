@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/chrisxf/traefik-tls-config-gen/matcher"
+	"github.com/chrisxf/traefik-tls-config-gen/tlsconfig"
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli"
+)
+
+// probeLabel replaces the wildcard of wildcard SANs, it is unlikely to be
+// routed to a more specific certificate.
+const probeLabel = "traefik-tls-config-gen-probe"
+
+var probeFlags = []cli.Flag{
+	cli.StringFlag{
+		Name:  "addr",
+		Usage: "Address of the Traefik TLS entry point to probe, as host:port",
+	},
+	cli.DurationFlag{
+		Name:  "probe-timeout",
+		Value: 5 * time.Second,
+		Usage: "Timeout of a single TLS handshake",
+	},
+	cli.DurationFlag{
+		Name:  "wait",
+		Usage: "Keep probing the mismatching names this long, to wait for Traefik to reload",
+	},
+}
+
+func fingerprint(raw []byte) string {
+	hash := sha256.Sum256(raw)
+	return hex.EncodeToString(hash[:])
+}
+
+// probeNames maps the server names to probe to the fingerprints of the
+// certificates valid for them. Wildcards are probed with a fixed label, IP
+// addresses are skipped as they cannot be sent as SNI.
+func probeNames(pairs []matcher.KeyPair) map[string]map[string]bool {
+	names := map[string]map[string]bool{}
+
+	for _, pair := range pairs {
+		for _, domain := range matcher.Domains(pair.Cert) {
+			if net.ParseIP(domain) != nil {
+				continue
+			}
+
+			name := strings.ToLower(domain)
+			if strings.HasPrefix(name, "*.") {
+				name = probeLabel + name[1:]
+			}
+
+			if names[name] == nil {
+				names[name] = map[string]bool{}
+			}
+
+			names[name][fingerprint(pair.Cert.Raw)] = true
+		}
+	}
+
+	return names
+}
+
+// serverCertificate returns the fingerprint of the leaf certificate addr
+// serves for name.
+func serverCertificate(ctx context.Context, addr string, name string, timeout time.Duration) (string, error) {
+	dialer := &tls.Dialer{
+		NetDialer: &net.Dialer{Timeout: timeout},
+		Config:    &tls.Config{ServerName: name, InsecureSkipVerify: true},
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return "", err
+	}
+
+	defer conn.Close()
+
+	certs := conn.(*tls.Conn).ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return "", errors.New("no certificate served")
+	}
+
+	return fingerprint(certs[0].Raw), nil
+}
+
+// probe connects to addr once for every name and returns the names served
+// with an unexpected certificate or failing the handshake.
+func probe(ctx context.Context, addr string, names map[string]map[string]bool, pending []string, timeout time.Duration) []string {
+	var failed []string
+
+	for _, name := range pending {
+		logger := log.WithField("name", name)
+
+		served, err := serverCertificate(ctx, addr, name, timeout)
+		if err != nil {
+			logger.WithError(err).Error("Could not probe server name")
+			failed = append(failed, name)
+			continue
+		}
+
+		if !names[name][served] {
+			logger.WithField("fingerprint", served).Error("Unexpected certificate served")
+			failed = append(failed, name)
+			continue
+		}
+
+		logger.Info("Expected certificate served")
+	}
+
+	return failed
+}
+
+func runProbe(c *cli.Context) {
+	checkArgs(c, false)
+
+	addr := c.String("addr")
+	if addr == "" {
+		log.Fatal("Address to probe not set!")
+	}
+
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, "443")
+	}
+
+	opts, err := loadOptions(c)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ctx, stop := signalContext()
+	defer stop()
+
+	ctx, cancel := runContext(ctx, c)
+	defer cancel()
+
+	result, err := tlsconfig.Scan(ctx, opts)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	names := probeNames(result.Pairs)
+
+	var pending []string
+	for name := range names {
+		pending = append(pending, name)
+	}
+
+	sort.Strings(pending)
+
+	deadline := time.Now().Add(c.Duration("wait"))
+
+	for {
+		pending = probe(ctx, addr, names, pending, c.Duration("probe-timeout"))
+		if len(pending) == 0 || time.Now().After(deadline) || ctx.Err() != nil {
+			break
+		}
+
+		log.WithField("names", len(pending)).Info("Probing mismatching names again")
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(2 * time.Second):
+		}
+	}
+
+	log.WithFields(log.Fields{"names": len(names), "failed": len(pending)}).Info("Probe finished")
+
+	if len(pending) > 0 {
+		os.Exit(1)
+	}
+}
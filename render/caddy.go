@@ -0,0 +1,70 @@
+package render
+
+import (
+	"encoding/json"
+
+	"github.com/chrisxf/traefik-tls-config-gen/matcher"
+)
+
+// caddyCertificate is an entry of load_files, or of load_pem with the
+// contents instead of the paths.
+type caddyCertificate struct {
+	Certificate string `json:"certificate"`
+	Key         string `json:"key"`
+	Format      string `json:"format,omitempty"`
+}
+
+type caddyCertificates struct {
+	LoadFiles []caddyCertificate `json:"load_files,omitempty"`
+	LoadPEM   []caddyCertificate `json:"load_pem,omitempty"`
+}
+
+type caddyConfig struct {
+	Apps struct {
+		TLS struct {
+			Certificates caddyCertificates `json:"certificates"`
+		} `json:"tls"`
+	} `json:"apps"`
+}
+
+func init() {
+	Register("caddy", func(opts *Options) Renderer {
+		return &caddyRenderer{opts: opts}
+	})
+}
+
+type caddyRenderer struct {
+	opts *Options
+}
+
+func (r *caddyRenderer) Render(pairs []matcher.KeyPair) ([]byte, error) {
+	return Caddy(pairs, r.opts)
+}
+
+// Caddy renders the pairs as the apps.tls.certificates part of a Caddy JSON
+// config, load_files with their paths or load_pem with EmbedFiles.
+func Caddy(pairs []matcher.KeyPair, opts *Options) ([]byte, error) {
+	var config caddyConfig
+
+	certificates := &config.Apps.TLS.Certificates
+
+	for _, pair := range pairs {
+		cert, err := opts.fileValue(pair.CertPath)
+		if err != nil {
+			return nil, err
+		}
+
+		key, err := opts.fileValue(pair.KeyPath)
+		if err != nil {
+			return nil, err
+		}
+
+		if opts.EmbedFiles {
+			certificates.LoadPEM = append(certificates.LoadPEM, caddyCertificate{Certificate: cert, Key: key})
+		} else {
+			certificates.LoadFiles = append(certificates.LoadFiles, caddyCertificate{Certificate: cert, Key: key, Format: "pem"})
+		}
+	}
+
+	return json.MarshalIndent(config, "", "  ")
+}
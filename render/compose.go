@@ -0,0 +1,121 @@
+package render
+
+import (
+	"bytes"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/chrisxf/traefik-tls-config-gen/matcher"
+)
+
+var unsafeRouterChars = regexp.MustCompile(`[^a-z0-9-]+`)
+
+func init() {
+	Register("compose-labels", func(opts *Options) Renderer {
+		return &composeRenderer{opts: opts}
+	})
+}
+
+type composeRenderer struct {
+	opts *Options
+}
+
+func (r *composeRenderer) Render(pairs []matcher.KeyPair) ([]byte, error) {
+	return ComposeLabels(pairs, r.opts), nil
+}
+
+// routerName returns the common name of the certificate, or its first DNS
+// name, as Traefik router name.
+func routerName(pair *matcher.KeyPair) string {
+	name := pair.Cert.Subject.CommonName
+	if name == "" && len(pair.Cert.DNSNames) > 0 {
+		name = pair.Cert.DNSNames[0]
+	}
+
+	name = strings.Replace(strings.ToLower(name), "*", "wildcard", -1)
+	name = strings.Trim(unsafeRouterChars.ReplaceAllString(name, "-"), "-")
+
+	if name == "" {
+		name = "cert"
+	}
+
+	return name
+}
+
+// ComposeLabels renders a docker-compose override setting the TLS labels of
+// a router per pair on the service ComposeService: its domains and, with
+// client CAs, the TLS options. Traefik reads certificates from the file
+// provider only, so the labels only cover the router side.
+func ComposeLabels(pairs []matcher.KeyPair, opts *Options) []byte {
+	buf := &bytes.Buffer{}
+
+	buf.Write([]byte(ConfigHeader + "\n"))
+	buf.Write([]byte("# Certificates are only loaded by the file provider, these labels\n"))
+	buf.Write([]byte("# route their domains over TLS.\n\n"))
+
+	service := opts.ComposeService
+	if service == "" {
+		service = "traefik"
+	}
+
+	buf.Write([]byte("services:\n"))
+	buf.Write([]byte("  " + strconv.Quote(service) + ":\n"))
+	buf.Write([]byte("    labels:\n"))
+
+	names := map[string]bool{}
+
+	for i := range pairs {
+		pair := &pairs[i]
+
+		name := routerName(pair)
+		for n := 2; names[name]; n++ {
+			name = routerName(pair) + "-" + strconv.Itoa(n)
+		}
+
+		names[name] = true
+
+		main := pair.Cert.Subject.CommonName
+		if main == "" && len(pair.Cert.DNSNames) > 0 {
+			main = pair.Cert.DNSNames[0]
+		}
+
+		var sans []string
+		for _, domain := range pair.Cert.DNSNames {
+			if domain != main {
+				sans = append(sans, domain)
+			}
+		}
+
+		if !opts.NoAnnotations {
+			buf.Write([]byte("      " + annotation(*pair)))
+		}
+
+		prefix := "traefik.http.routers." + name + ".tls"
+		labels := []string{prefix + "=true"}
+
+		if main != "" {
+			labels = append(labels, prefix+".domains[0].main="+main)
+		}
+
+		if len(sans) > 0 {
+			labels = append(labels, prefix+".domains[0].sans="+strings.Join(sans, ","))
+		}
+
+		if opts.ClientAuth != "" && len(opts.CAFiles) > 0 {
+			labels = append(labels, prefix+".options="+opts.ClientAuth+"@file")
+		}
+
+		for _, label := range labels {
+			buf.Write([]byte("      - " + strconv.Quote(label) + "\n"))
+		}
+	}
+
+	if len(pairs) == 0 {
+		buf.Write([]byte("      []\n"))
+	}
+
+	buf.Write([]byte("\n" + ConfigFooter))
+
+	return buf.Bytes()
+}
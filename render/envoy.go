@@ -0,0 +1,163 @@
+package render
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/chrisxf/traefik-tls-config-gen/matcher"
+)
+
+const envoySecretType = "type.googleapis.com/envoy.extensions.transport_sockets.tls.v3.Secret"
+
+type envoyDataSource struct {
+	Filename     string `json:"filename,omitempty"`
+	InlineString string `json:"inline_string,omitempty"`
+}
+
+type envoyTLSCertificate struct {
+	CertificateChain envoyDataSource `json:"certificate_chain"`
+	PrivateKey       envoyDataSource `json:"private_key"`
+}
+
+type envoyValidationContext struct {
+	TrustedCA envoyDataSource `json:"trusted_ca"`
+}
+
+type envoySecret struct {
+	Type              string                  `json:"@type"`
+	Name              string                  `json:"name"`
+	TLSCertificate    *envoyTLSCertificate    `json:"tls_certificate,omitempty"`
+	ValidationContext *envoyValidationContext `json:"validation_context,omitempty"`
+}
+
+type envoyDiscoveryResponse struct {
+	VersionInfo string        `json:"version_info"`
+	Resources   []envoySecret `json:"resources"`
+}
+
+func init() {
+	Register("envoy-sds", func(opts *Options) Renderer {
+		return &envoyRenderer{opts: opts}
+	})
+}
+
+type envoyRenderer struct {
+	opts *Options
+}
+
+func (r *envoyRenderer) Render(pairs []matcher.KeyPair) ([]byte, error) {
+	return EnvoySDS(pairs, r.opts)
+}
+
+// dataSource returns path as file name or, with EmbedFiles, its content.
+func (o *Options) dataSource(path string) (envoyDataSource, error) {
+	value, err := o.fileValue(path)
+	if err != nil {
+		return envoyDataSource{}, err
+	}
+
+	if o.EmbedFiles {
+		return envoyDataSource{InlineString: value}, nil
+	}
+
+	return envoyDataSource{Filename: value}, nil
+}
+
+// tlsCertificateSecret returns the tls_certificate secret of pair with name.
+func (o *Options) tlsCertificateSecret(name string, pair *matcher.KeyPair) (envoySecret, error) {
+	chain, err := o.dataSource(pair.CertPath)
+	if err != nil {
+		return envoySecret{}, err
+	}
+
+	key, err := o.dataSource(pair.KeyPath)
+	if err != nil {
+		return envoySecret{}, err
+	}
+
+	return envoySecret{
+		Type:           envoySecretType,
+		Name:           name,
+		TLSCertificate: &envoyTLSCertificate{CertificateChain: chain, PrivateKey: key},
+	}, nil
+}
+
+// envoySecretName returns the lower-cased common name of the certificate, or
+// its first DNS name.
+func envoySecretName(pair *matcher.KeyPair) string {
+	name := pair.Cert.Subject.CommonName
+	if name == "" && len(pair.Cert.DNSNames) > 0 {
+		name = pair.Cert.DNSNames[0]
+	}
+
+	if name == "" {
+		name = "cert"
+	}
+
+	return strings.ToLower(name)
+}
+
+// EnvoySDS renders the pairs as SDS discovery response with a tls_certificate
+// secret per pair, named after the certificate, a "default" secret for the
+// default pair and a validation_context secret "client-ca" per CA file. The
+// response is read by Envoy from a path_config_source or served as REST SDS.
+func EnvoySDS(pairs []matcher.KeyPair, opts *Options) ([]byte, error) {
+	response := envoyDiscoveryResponse{Resources: []envoySecret{}}
+	names := map[string]bool{"default": true, "client-ca": true}
+
+	for i := range pairs {
+		name := envoySecretName(&pairs[i])
+		for n := 2; names[name]; n++ {
+			name = envoySecretName(&pairs[i]) + "-" + strconv.Itoa(n)
+		}
+
+		names[name] = true
+
+		secret, err := opts.tlsCertificateSecret(name, &pairs[i])
+		if err != nil {
+			return nil, err
+		}
+
+		response.Resources = append(response.Resources, secret)
+	}
+
+	if defaultPair := DefaultPair(pairs, opts); defaultPair != nil {
+		secret, err := opts.tlsCertificateSecret("default", defaultPair)
+		if err != nil {
+			return nil, err
+		}
+
+		response.Resources = append(response.Resources, secret)
+	}
+
+	for i, caFile := range opts.CAFiles {
+		ca, err := opts.dataSource(caFile)
+		if err != nil {
+			return nil, err
+		}
+
+		name := "client-ca"
+		if i > 0 {
+			name += "-" + strconv.Itoa(i+1)
+		}
+
+		response.Resources = append(response.Resources, envoySecret{
+			Type:              envoySecretType,
+			Name:              name,
+			ValidationContext: &envoyValidationContext{TrustedCA: ca},
+		})
+	}
+
+	resources, err := json.Marshal(response.Resources)
+	if err != nil {
+		return nil, err
+	}
+
+	version := sha256.Sum256(resources)
+	response.VersionInfo = hex.EncodeToString(version[:8])
+
+	return json.MarshalIndent(response, "", "  ")
+}
@@ -0,0 +1,61 @@
+package render
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+
+	"github.com/chrisxf/traefik-tls-config-gen/matcher"
+	log "github.com/sirupsen/logrus"
+)
+
+func init() {
+	Register("haproxy", func(opts *Options) Renderer {
+		return &haproxyRenderer{opts: opts}
+	})
+}
+
+type haproxyRenderer struct {
+	opts *Options
+}
+
+func (r *haproxyRenderer) Render(pairs []matcher.KeyPair) ([]byte, error) {
+	return HAProxy(pairs, r.opts), nil
+}
+
+// HAProxy returns a crt-list with a line per pair, the default pair first as
+// HAProxy serves the first certificate to clients without matching SNI.
+// HAProxy only finds separate key files named like the certificate with the
+// .key extension, with "ssl-load-extra-files key".
+func HAProxy(pairs []matcher.KeyPair, opts *Options) []byte {
+	buf := &bytes.Buffer{}
+
+	buf.Write([]byte(ConfigHeader + "\n\n"))
+
+	if defaultPair := DefaultPair(pairs, opts); defaultPair != nil {
+		pairs = append([]matcher.KeyPair{*defaultPair}, pairs...)
+
+		for i := 1; i < len(pairs); i++ {
+			if pairs[i].CertPath == defaultPair.CertPath {
+				pairs = append(pairs[:i], pairs[i+1:]...)
+				break
+			}
+		}
+	}
+
+	for _, pair := range pairs {
+		if pair.KeyPath != pair.CertPath && pair.KeyPath != strings.TrimSuffix(pair.CertPath, filepath.Ext(pair.CertPath))+".key" {
+			log.WithFields(log.Fields{"path": pair.CertPath, "keyFile": pair.KeyPath}).Warn("HAProxy will not find the key file, use --copy-mode bundle")
+		}
+
+		if !opts.NoAnnotations {
+			buf.Write([]byte(annotation(pair)))
+		}
+
+		buf.Write([]byte(opts.ConfigPath(pair.CertPath) + "\n\n"))
+	}
+
+	buf.Write([]byte(ConfigFooter))
+
+	return buf.Bytes()
+}
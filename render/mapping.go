@@ -0,0 +1,65 @@
+package render
+
+import (
+	"crypto/x509"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/chrisxf/traefik-tls-config-gen/matcher"
+	"gopkg.in/yaml.v2"
+)
+
+// MappingRule assigns entry points and TLS stores to all certificates with a
+// SAN matching one of the domain globs.
+type MappingRule struct {
+	Domains     []string `yaml:"domains" toml:"domains"`
+	EntryPoints []string `yaml:"entryPoints" toml:"entryPoints"`
+	Stores      []string `yaml:"stores" toml:"stores"`
+}
+
+type DomainMapping struct {
+	Rules []MappingRule `yaml:"rules" toml:"rules"`
+}
+
+// LoadDomainMapping reads a YAML or TOML mapping file, picked by extension.
+func LoadDomainMapping(file string) (*DomainMapping, error) {
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	mapping := &DomainMapping{}
+
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".yaml", ".yml":
+		err = yaml.UnmarshalStrict(content, mapping)
+	case ".toml":
+		_, err = toml.Decode(string(content), mapping)
+	default:
+		err = errors.New("unknown mapping file format " + filepath.Ext(file))
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return mapping, nil
+}
+
+// lookup returns the first rule matching any of the certificate's domains.
+func (m *DomainMapping) lookup(cert *x509.Certificate) *MappingRule {
+	if m == nil || cert == nil {
+		return nil
+	}
+
+	for i, rule := range m.Rules {
+		if matcher.MatchDomains(rule.Domains, cert) {
+			return &m.Rules[i]
+		}
+	}
+
+	return nil
+}
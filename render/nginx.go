@@ -0,0 +1,143 @@
+package render
+
+import (
+	"bytes"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/chrisxf/traefik-tls-config-gen/matcher"
+)
+
+func init() {
+	Register("nginx", func(opts *Options) Renderer {
+		return &nginxRenderer{opts: opts}
+	})
+	Register("nginx-snippet", func(opts *Options) Renderer {
+		return &nginxSnippetRenderer{opts: opts}
+	})
+}
+
+type nginxRenderer struct {
+	opts *Options
+}
+
+func (r *nginxRenderer) Render(pairs []matcher.KeyPair) ([]byte, error) {
+	return NginxMap(pairs, r.opts), nil
+}
+
+type nginxSnippetRenderer struct {
+	opts *Options
+}
+
+// Render returns the snippet of a single pair, as it is included in the
+// server block of the pair's domains.
+func (r *nginxSnippetRenderer) Render(pairs []matcher.KeyPair) ([]byte, error) {
+	if len(pairs) > 1 {
+		return nil, errors.New("nginx-snippet output holds a single pair, found " + strconv.Itoa(len(pairs)) + ", set --out-dir")
+	}
+
+	return NginxSnippet(pairs, r.opts), nil
+}
+
+// nginxString quotes value if nginx would not read it as one parameter.
+func nginxString(value string) string {
+	if value != "" && !strings.ContainsAny(value, " \t\r\n;{}\"'\\#") {
+		return value
+	}
+
+	value = strings.Replace(value, "\\", "\\\\", -1)
+	value = strings.Replace(value, "\"", "\\\"", -1)
+
+	return "\"" + value + "\""
+}
+
+// NginxSnippet returns the ssl_certificate and ssl_certificate_key directives
+// of the pairs, meant to be written per pair with --out-dir and included in
+// the server blocks.
+func NginxSnippet(pairs []matcher.KeyPair, opts *Options) []byte {
+	buf := &bytes.Buffer{}
+
+	buf.Write([]byte(ConfigHeader + "\n\n"))
+
+	for _, pair := range pairs {
+		if !opts.NoAnnotations {
+			buf.Write([]byte(annotation(pair)))
+		}
+
+		buf.Write([]byte("ssl_certificate " + nginxString(opts.ConfigPath(pair.CertPath)) + ";\n"))
+		buf.Write([]byte("ssl_certificate_key " + nginxString(opts.ConfigPath(pair.KeyPath)) + ";\n\n"))
+	}
+
+	buf.Write([]byte(ConfigFooter))
+
+	return buf.Bytes()
+}
+
+// NginxMap returns maps from $ssl_server_name to the cert and key files of
+// the pair covering it, for "ssl_certificate $tls_certificate;" and
+// "ssl_certificate_key $tls_certificate_key;". Names covered by several
+// pairs use the first, names covered by none the default pair or else the
+// first one.
+func NginxMap(pairs []matcher.KeyPair, opts *Options) []byte {
+	buf := &bytes.Buffer{}
+
+	buf.Write([]byte(ConfigHeader + "\n\n"))
+
+	defaultPair := DefaultPair(pairs, opts)
+	if defaultPair == nil && len(pairs) > 0 {
+		defaultPair = &pairs[0]
+	}
+
+	for _, variable := range []string{"tls_certificate", "tls_certificate_key"} {
+		path := func(pair *matcher.KeyPair) string {
+			if variable == "tls_certificate" {
+				return nginxString(opts.ConfigPath(pair.CertPath))
+			}
+
+			return nginxString(opts.ConfigPath(pair.KeyPath))
+		}
+
+		buf.Write([]byte("map $ssl_server_name $" + variable + " {\n"))
+		buf.Write([]byte("    hostnames;\n"))
+
+		if defaultPair != nil {
+			buf.Write([]byte("    default " + path(defaultPair) + ";\n"))
+		}
+
+		seen := map[string]bool{}
+
+		for i := range pairs {
+			pair := &pairs[i]
+
+			var names []string
+			for _, name := range pair.Cert.DNSNames {
+				name = strings.ToLower(name)
+				if !seen[name] {
+					seen[name] = true
+					names = append(names, name)
+				}
+			}
+
+			if len(names) == 0 {
+				continue
+			}
+
+			buf.Write([]byte("\n"))
+
+			if !opts.NoAnnotations {
+				buf.Write([]byte("    " + annotation(*pair)))
+			}
+
+			for _, name := range names {
+				buf.Write([]byte("    " + nginxString(name) + " " + path(pair) + ";\n"))
+			}
+		}
+
+		buf.Write([]byte("}\n\n"))
+	}
+
+	buf.Write([]byte(ConfigFooter))
+
+	return buf.Bytes()
+}
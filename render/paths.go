@@ -0,0 +1,58 @@
+package render
+
+import (
+	"errors"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// PathMap rewrites the paths written into the config. Without Regexp, paths
+// below the directory From are moved below To, otherwise the matches of
+// Regexp are replaced with To, which may refer to its groups as $1.
+type PathMap struct {
+	From   string
+	Regexp *regexp.Regexp
+	To     string
+}
+
+// ParsePathMap parses "FROM=>TO" as directory substitution and "~REGEX=>TO"
+// as regular expression rewrite.
+func ParsePathMap(spec string) (PathMap, error) {
+	parts := strings.SplitN(spec, "=>", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[0] == "~" {
+		return PathMap{}, errors.New("path map must be FROM=>TO or ~REGEX=>TO: " + spec)
+	}
+
+	if !strings.HasPrefix(parts[0], "~") {
+		return PathMap{From: filepath.Clean(parts[0]), To: parts[1]}, nil
+	}
+
+	re, err := regexp.Compile(parts[0][1:])
+	if err != nil {
+		return PathMap{}, err
+	}
+
+	return PathMap{Regexp: re, To: parts[1]}, nil
+}
+
+// apply returns path rewritten by the map and whether it matched.
+func (m *PathMap) apply(path string) (string, bool) {
+	if m.Regexp != nil {
+		if !m.Regexp.MatchString(path) {
+			return path, false
+		}
+
+		return m.Regexp.ReplaceAllString(path, m.To), true
+	}
+
+	if path == m.From {
+		return m.To, true
+	}
+
+	if rest := strings.TrimPrefix(path, m.From+string(filepath.Separator)); rest != path {
+		return filepath.Join(m.To, rest), true
+	}
+
+	return path, false
+}
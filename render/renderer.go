@@ -0,0 +1,46 @@
+package render
+
+import (
+	"errors"
+	"sort"
+
+	"github.com/chrisxf/traefik-tls-config-gen/matcher"
+)
+
+// Renderer turns matched pairs into the autogenerated block of a config file.
+type Renderer interface {
+	Render(pairs []matcher.KeyPair) ([]byte, error)
+}
+
+// Factory creates a renderer for the given options.
+type Factory func(opts *Options) Renderer
+
+var renderers = map[string]Factory{}
+
+// Register makes a renderer available under the given output format name.
+// It is meant to be called from init functions.
+func Register(format string, factory Factory) {
+	renderers[format] = factory
+}
+
+// New returns the renderer registered for the given output format.
+func New(format string, opts *Options) (Renderer, error) {
+	factory, ok := renderers[format]
+	if !ok {
+		return nil, errors.New("unknown output format " + format)
+	}
+
+	return factory(opts), nil
+}
+
+// Formats returns the names of all registered output formats.
+func Formats() []string {
+	var formats []string
+	for format := range renderers {
+		formats = append(formats, format)
+	}
+
+	sort.Strings(formats)
+
+	return formats
+}
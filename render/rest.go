@@ -0,0 +1,155 @@
+package render
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+
+	"github.com/chrisxf/traefik-tls-config-gen/matcher"
+)
+
+type restCertificate struct {
+	CertFile string   `json:"certFile"`
+	KeyFile  string   `json:"keyFile"`
+	Stores   []string `json:"stores,omitempty"`
+}
+
+type restStore struct {
+	DefaultCertificate *restCertificate `json:"defaultCertificate,omitempty"`
+}
+
+type restClientAuth struct {
+	CAFiles        []string `json:"caFiles"`
+	ClientAuthType string   `json:"clientAuthType"`
+}
+
+type restOptions struct {
+	MinVersion            string          `json:"minVersion,omitempty"`
+	SNIStrict             bool            `json:"sniStrict,omitempty"`
+	DisableSessionTickets bool            `json:"disableSessionTickets,omitempty"`
+	ClientAuth            *restClientAuth `json:"clientAuth,omitempty"`
+}
+
+type restTLS struct {
+	Certificates []restCertificate      `json:"certificates"`
+	Stores       map[string]restStore   `json:"stores,omitempty"`
+	Options      map[string]restOptions `json:"options,omitempty"`
+}
+
+type restConfig struct {
+	TLS restTLS `json:"tls"`
+}
+
+func init() {
+	factory := func(opts *Options) Renderer {
+		return &traefikJSONRenderer{opts: opts}
+	}
+
+	Register("traefik-json", factory)
+	Register("json", factory)
+}
+
+type traefikJSONRenderer struct {
+	opts *Options
+}
+
+// Render returns the dynamic config of TraefikJSON as a whole file, for the
+// REST provider or the file provider. JSON has no comments, so the file is
+// replaced instead of merged and carries no annotations.
+func (r *traefikJSONRenderer) Render(pairs []matcher.KeyPair) ([]byte, error) {
+	if r.opts.TraefikVersion < 2 {
+		return nil, errors.New("json output requires Traefik v2 or later, set --traefik-version")
+	}
+
+	content, err := TraefikJSON(pairs, r.opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(content, '\n'), nil
+}
+
+// fileValue returns the config path of path or its content if EmbedFiles is
+// set.
+func (o *Options) fileValue(path string) (string, error) {
+	if !o.EmbedFiles {
+		return o.ConfigPath(path), nil
+	}
+
+	content, err := ioutil.ReadFile(path)
+
+	return string(content), err
+}
+
+// restCertificate returns the certificate entry of pair.
+func (o *Options) restCertificate(pair *matcher.KeyPair) (*restCertificate, error) {
+	certFile, err := o.fileValue(pair.CertPath)
+	if err != nil {
+		return nil, err
+	}
+
+	keyFile, err := o.fileValue(pair.KeyPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return &restCertificate{CertFile: certFile, KeyFile: keyFile}, nil
+}
+
+// TraefikJSON renders the pairs as Traefik v2 or v3 dynamic configuration in
+// JSON, as expected by the REST provider.
+func TraefikJSON(pairs []matcher.KeyPair, opts *Options) ([]byte, error) {
+	config := restConfig{TLS: restTLS{Certificates: []restCertificate{}}}
+
+	opts.warnVersionFeatures()
+
+	for i := range pairs {
+		pair := &pairs[i]
+
+		cert, err := opts.restCertificate(pair)
+		if err != nil {
+			return nil, err
+		}
+
+		cert.Stores = opts.certStores(pair.Cert)
+
+		config.TLS.Certificates = append(config.TLS.Certificates, *cert)
+	}
+
+	if defaultPair := DefaultPair(pairs, opts); defaultPair != nil {
+		cert, err := opts.restCertificate(defaultPair)
+		if err != nil {
+			return nil, err
+		}
+
+		config.TLS.Stores = map[string]restStore{"default": {DefaultCertificate: cert}}
+	}
+
+	config.TLS.Options = map[string]restOptions{}
+
+	if opts.hasDefaultOptions() {
+		config.TLS.Options["default"] = restOptions{
+			MinVersion:            opts.MinTLSVersion,
+			SNIStrict:             opts.SNIStrict,
+			DisableSessionTickets: opts.DisableSessionTickets && opts.TraefikVersion >= 3,
+		}
+	}
+
+	if len(opts.CAFiles) > 0 {
+		var caFiles []string
+		for _, caFile := range opts.CAFiles {
+			caFile, err := opts.fileValue(caFile)
+			if err != nil {
+				return nil, err
+			}
+
+			caFiles = append(caFiles, caFile)
+		}
+
+		options := config.TLS.Options[opts.ClientAuth]
+		options.ClientAuth = &restClientAuth{CAFiles: caFiles, ClientAuthType: opts.ClientAuthType}
+		config.TLS.Options[opts.ClientAuth] = options
+	}
+
+	return json.MarshalIndent(config, "", "  ")
+}
@@ -0,0 +1,56 @@
+package render
+
+import (
+	"bytes"
+	"strconv"
+	"time"
+)
+
+// Metadata describes a run for Stamp. A zero Time is omitted, so unchanged
+// pairs render the same content.
+type Metadata struct {
+	Time       time.Time
+	Version    string
+	SourceHash string
+	Pairs      int
+}
+
+// Stamp inserts the metadata as comment lines below the header of block.
+// Blocks without header, like the JSON formats, are returned unchanged.
+func Stamp(block []byte, meta Metadata) []byte {
+	if !bytes.HasPrefix(block, []byte(ConfigHeader+"\n")) {
+		return block
+	}
+
+	buf := &bytes.Buffer{}
+	buf.Write([]byte(ConfigHeader + "\n"))
+
+	if !meta.Time.IsZero() {
+		buf.Write([]byte("# Generated: " + meta.Time.UTC().Format(time.RFC3339) + "\n"))
+	}
+
+	buf.Write([]byte("# Version: " + meta.Version + "\n"))
+	buf.Write([]byte("# Source hash: " + meta.SourceHash + "\n"))
+	buf.Write([]byte("# Pairs: " + strconv.Itoa(meta.Pairs) + "\n"))
+	buf.Write(block[len(ConfigHeader)+1:])
+
+	return buf.Bytes()
+}
+
+// StripMarkers removes the header and footer from block, so the file only
+// contains the rendered config.
+func StripMarkers(block []byte) []byte {
+	if !bytes.HasPrefix(block, []byte(ConfigHeader)) {
+		return block
+	}
+
+	block = bytes.TrimPrefix(block, []byte(ConfigHeader))
+	block = bytes.TrimSuffix(block, []byte(ConfigFooter))
+	block = bytes.Trim(block, "\n")
+
+	if len(block) == 0 {
+		return nil
+	}
+
+	return append(block, '\n')
+}
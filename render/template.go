@@ -0,0 +1,93 @@
+package render
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/chrisxf/traefik-tls-config-gen/matcher"
+)
+
+func init() {
+	Register("template", func(opts *Options) Renderer {
+		return &templateRenderer{opts: opts}
+	})
+}
+
+// TemplatePair is the data passed to user templates for every pair.
+type TemplatePair struct {
+	CertFile    string
+	KeyFile     string
+	CommonName  string
+	DNSNames    []string
+	Issuer      string
+	NotBefore   time.Time
+	NotAfter    time.Time
+	EntryPoints []string
+	Stores      []string
+	Default     bool
+}
+
+// TemplateData is the root object passed to user templates.
+type TemplateData struct {
+	Pairs   []TemplatePair
+	CAFiles []string
+}
+
+var templateFuncs = template.FuncMap{
+	"join": strings.Join,
+}
+
+type templateRenderer struct {
+	opts *Options
+}
+
+// Render executes the template file set in the options. The template is
+// read on every run, so changes are picked up in watch mode.
+func (r *templateRenderer) Render(pairs []matcher.KeyPair) ([]byte, error) {
+	tmpl, err := template.New(filepath.Base(r.opts.Template)).Funcs(templateFuncs).ParseFiles(r.opts.Template)
+	if err != nil {
+		return nil, err
+	}
+
+	data := &TemplateData{}
+
+	defaultPair := DefaultPair(pairs, r.opts)
+
+	for i, pair := range pairs {
+		entryPoints := r.opts.EntryPoints
+		stores := r.opts.certStores(pair.Cert)
+
+		if rule := r.opts.Mapping.lookup(pair.Cert); rule != nil && len(rule.EntryPoints) > 0 {
+			entryPoints = rule.EntryPoints
+		}
+
+		data.Pairs = append(data.Pairs, TemplatePair{
+			CertFile:    r.opts.ConfigPath(pair.CertPath),
+			KeyFile:     r.opts.ConfigPath(pair.KeyPath),
+			CommonName:  pair.Cert.Subject.CommonName,
+			DNSNames:    pair.Cert.DNSNames,
+			Issuer:      pair.Cert.Issuer.CommonName,
+			NotBefore:   pair.Cert.NotBefore,
+			NotAfter:    pair.Cert.NotAfter,
+			EntryPoints: entryPoints,
+			Stores:      stores,
+			Default:     defaultPair == &pairs[i],
+		})
+	}
+
+	for _, caFile := range r.opts.CAFiles {
+		data.CAFiles = append(data.CAFiles, r.opts.ConfigPath(caFile))
+	}
+
+	buf := &bytes.Buffer{}
+
+	err = tmpl.Execute(buf, data)
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,401 @@
+// Package render turns matched certificate pairs into Traefik configuration.
+package render
+
+import (
+	"bytes"
+	"crypto/x509"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/chrisxf/traefik-tls-config-gen/matcher"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	ConfigHeader = "# ~~~ Autogenerated config start - Do not touch! ~~~"
+	ConfigFooter = "# ~~~ Autogenerated config end ~~~"
+)
+
+type Options struct {
+	PathPrefix string
+	// DirPrefixes maps input directories to the path prefix used for files
+	// below them instead of PathPrefix.
+	DirPrefixes map[string]string
+	// PathMaps rewrite the paths instead of the prefixes, the first
+	// matching one is used.
+	PathMaps []PathMap
+	// RelativeTo, if set, makes the paths relative to the directory.
+	RelativeTo string
+	// PosixPaths writes the paths with forward slashes on all systems.
+	PosixPaths     bool
+	EntryPoints    []string
+	TraefikVersion int
+	Mapping        *DomainMapping
+	DefaultDomain  string
+	DefaultCert    string
+	CAFiles        []string
+	ClientAuth     string
+	ClientAuthType string
+	// MinTLSVersion, SNIStrict and DisableSessionTickets, which requires
+	// Traefik v3, are written to the "default" TLS options of Traefik v2 and
+	// later.
+	MinTLSVersion         string
+	SNIStrict             bool
+	DisableSessionTickets bool
+	// Stores are the TLS stores of the certificates not assigned any by
+	// the Mapping.
+	Stores []string
+	// Template is the template file used by the template renderer.
+	Template string
+	// NoAnnotations omits the comment with CN, SANs and expiry above every
+	// certificate entry.
+	NoAnnotations bool
+	// ComposeService is the service the compose-labels format sets the
+	// labels on, "traefik" if empty.
+	ComposeService string
+	// EmbedFiles makes TraefikJSON write the PEM content of the files
+	// instead of their paths, which Traefik accepts as well.
+	EmbedFiles bool
+}
+
+func init() {
+	Register("traefik", func(opts *Options) Renderer {
+		return &traefikRenderer{opts: opts}
+	})
+}
+
+type traefikRenderer struct {
+	opts *Options
+}
+
+func (r *traefikRenderer) Render(pairs []matcher.KeyPair) ([]byte, error) {
+	return Traefik(pairs, r.opts), nil
+}
+
+// ConfigPath returns path as it is written into the config, rewritten by the
+// first matching PathMaps entry, or made relative to RelativeTo and prefixed
+// with the prefix of the innermost directory in DirPrefixes containing it, or
+// with PathPrefix. PosixPaths converts the separators to forward slashes.
+func (o *Options) ConfigPath(path string) string {
+	path = o.configPath(path)
+
+	if o.PosixPaths {
+		return filepath.ToSlash(path)
+	}
+
+	return path
+}
+
+func (o *Options) configPath(path string) string {
+	for i := range o.PathMaps {
+		if mapped, ok := o.PathMaps[i].apply(path); ok {
+			return mapped
+		}
+	}
+
+	prefix := o.PathPrefix
+	longest := -1
+
+	for dir, dirPrefix := range o.DirPrefixes {
+		dir = filepath.Clean(dir)
+		if len(dir) > longest && (path == dir || strings.HasPrefix(path, dir+string(filepath.Separator))) {
+			prefix = dirPrefix
+			longest = len(dir)
+		}
+	}
+
+	if o.RelativeTo != "" {
+		path = relativePath(o.RelativeTo, path)
+	}
+
+	return filepath.Join(prefix, path)
+}
+
+// relativePath returns path relative to base, or path itself if there is no
+// relative path, like for another volume.
+func relativePath(base string, path string) string {
+	absBase, err := filepath.Abs(base)
+	if err != nil {
+		return path
+	}
+
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+
+	rel, err := filepath.Rel(absBase, absPath)
+	if err != nil {
+		return path
+	}
+
+	return rel
+}
+
+// annotation returns a comment line describing the certificate of pair.
+func annotation(pair matcher.KeyPair) string {
+	comment := "CN=" + pair.Cert.Subject.CommonName +
+		" SANs=" + strings.Join(pair.Cert.DNSNames, ",") +
+		" NotAfter=" + pair.Cert.NotAfter.UTC().Format(time.RFC3339)
+
+	// Comments end at the line break, so control characters from the
+	// certificate must not end up in them.
+	comment = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return '?'
+		}
+
+		return r
+	}, comment)
+
+	return "# " + comment + "\n"
+}
+
+// tomlString quotes value as a TOML basic string.
+func tomlString(value string) string {
+	buf := &strings.Builder{}
+	buf.WriteByte('"')
+
+	for _, r := range value {
+		switch r {
+		case '"':
+			buf.WriteString("\\\"")
+		case '\\':
+			buf.WriteString("\\\\")
+		case '\b':
+			buf.WriteString("\\b")
+		case '\t':
+			buf.WriteString("\\t")
+		case '\n':
+			buf.WriteString("\\n")
+		case '\f':
+			buf.WriteString("\\f")
+		case '\r':
+			buf.WriteString("\\r")
+		default:
+			if r < 0x20 || r == 0x7f {
+				fmt.Fprintf(buf, "\\u%04X", r)
+			} else {
+				buf.WriteRune(r)
+			}
+		}
+	}
+
+	buf.WriteByte('"')
+
+	return buf.String()
+}
+
+var bareKeyPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// tomlKey returns key as is if it is a valid bare key, quoted otherwise.
+func tomlKey(key string) string {
+	if bareKeyPattern.MatchString(key) {
+		return key
+	}
+
+	return tomlString(key)
+}
+
+func tomlStringArray(values []string) string {
+	var quoted []string
+	for _, value := range values {
+		quoted = append(quoted, tomlString(value))
+	}
+
+	return "[" + strings.Join(quoted, ", ") + "]"
+}
+
+// Traefik renders the autogenerated block of the Traefik file provider config,
+// including the start and end markers.
+func Traefik(pairs []matcher.KeyPair, opts *Options) []byte {
+	buf := &bytes.Buffer{}
+
+	buf.Write([]byte(ConfigHeader + "\n\n"))
+
+	opts.warnVersionFeatures()
+
+	for _, pair := range pairs {
+		certPath := opts.ConfigPath(pair.CertPath)
+		keyPath := opts.ConfigPath(pair.KeyPath)
+
+		entryPoints := opts.EntryPoints
+		stores := opts.certStores(pair.Cert)
+
+		if rule := opts.Mapping.lookup(pair.Cert); rule != nil && len(rule.EntryPoints) > 0 {
+			entryPoints = rule.EntryPoints
+		}
+
+		if !opts.NoAnnotations {
+			buf.Write([]byte(annotation(pair)))
+		}
+
+		if opts.TraefikVersion >= 2 {
+			buf.Write([]byte("[[tls.certificates]]\n"))
+			buf.Write([]byte("  certFile = " + tomlString(certPath) + "\n"))
+			buf.Write([]byte("  keyFile = " + tomlString(keyPath) + "\n"))
+			if len(stores) > 0 {
+				buf.Write([]byte("  stores = " + tomlStringArray(stores) + "\n"))
+			}
+			buf.Write([]byte("\n"))
+			continue
+		}
+
+		buf.Write([]byte("[[tls]]\n"))
+		if len(entryPoints) > 0 {
+			buf.Write([]byte("  entryPoints = " + tomlStringArray(entryPoints) + "\n"))
+		}
+		buf.Write([]byte("  [tls.certificate]\n"))
+		buf.Write([]byte("    certFile = " + tomlString(certPath) + "\n"))
+		buf.Write([]byte("    keyFile = " + tomlString(keyPath) + "\n"))
+		buf.Write([]byte("\n"))
+	}
+
+	if defaultPair := DefaultPair(pairs, opts); defaultPair != nil {
+		if opts.TraefikVersion >= 2 {
+			buf.Write([]byte("[tls.stores.default.defaultCertificate]\n"))
+			buf.Write([]byte("  certFile = " + tomlString(opts.ConfigPath(defaultPair.CertPath)) + "\n"))
+			buf.Write([]byte("  keyFile = " + tomlString(opts.ConfigPath(defaultPair.KeyPath)) + "\n"))
+			buf.Write([]byte("\n"))
+		} else {
+			log.Warn("Default certificate is only supported for Traefik v2 and later, ignoring")
+		}
+	}
+
+	if opts.hasDefaultOptions() {
+		if opts.TraefikVersion >= 2 {
+			buf.Write([]byte("[tls.options.default]\n"))
+			if opts.MinTLSVersion != "" {
+				buf.Write([]byte("  minVersion = " + tomlString(opts.MinTLSVersion) + "\n"))
+			}
+			if opts.SNIStrict {
+				buf.Write([]byte("  sniStrict = true\n"))
+			}
+			if opts.DisableSessionTickets && opts.TraefikVersion >= 3 {
+				buf.Write([]byte("  disableSessionTickets = true\n"))
+			}
+			buf.Write([]byte("\n"))
+		} else {
+			log.Warn("TLS options are only supported for Traefik v2 and later, ignoring")
+		}
+	}
+
+	if len(opts.CAFiles) > 0 {
+		if opts.TraefikVersion >= 2 {
+			var caFiles []string
+			for _, caFile := range opts.CAFiles {
+				caFiles = append(caFiles, opts.ConfigPath(caFile))
+			}
+
+			buf.Write([]byte("[tls.options." + tomlKey(opts.ClientAuth) + ".clientAuth]\n"))
+			buf.Write([]byte("  caFiles = " + tomlStringArray(caFiles) + "\n"))
+			buf.Write([]byte("  clientAuthType = " + tomlString(opts.ClientAuthType) + "\n"))
+			buf.Write([]byte("\n"))
+		} else {
+			log.Warn("Client CA configuration is only supported for Traefik v2 and later, ignoring")
+		}
+	}
+
+	buf.Write([]byte(ConfigFooter))
+
+	return buf.Bytes()
+}
+
+// certStores returns the stores of the mapping rule matching cert, or else
+// the global Stores.
+func (o *Options) certStores(cert *x509.Certificate) []string {
+	if rule := o.Mapping.lookup(cert); rule != nil && len(rule.Stores) > 0 {
+		return rule.Stores
+	}
+
+	return o.Stores
+}
+
+// hasDefaultOptions reports whether any of the default TLS options is set.
+func (o *Options) hasDefaultOptions() bool {
+	return o.MinTLSVersion != "" || o.SNIStrict || o.DisableSessionTickets
+}
+
+// warnVersionFeatures logs the options TraefikVersion does not support.
+func (o *Options) warnVersionFeatures() {
+	if o.DisableSessionTickets && o.TraefikVersion < 3 {
+		log.Warn("Disabling session tickets is only supported for Traefik v3 and later, ignoring")
+	}
+
+	if o.TraefikVersion < 2 || o.Mapping == nil {
+		return
+	}
+
+	// Since Traefik v2 the certificates have no entry points, the routers
+	// select them instead.
+	for _, rule := range o.Mapping.Rules {
+		if len(rule.EntryPoints) > 0 {
+			log.WithField("domains", strings.Join(rule.Domains, ",")).Warn("Entry points of mapping rules are only supported by Traefik v1, ignoring")
+		}
+	}
+}
+
+// DefaultPair returns the pair selected as default certificate, either by
+// cert path or by domain. If several certificates cover the domain, the one
+// expiring last wins.
+func DefaultPair(pairs []matcher.KeyPair, opts *Options) *matcher.KeyPair {
+	var defaultPair *matcher.KeyPair
+
+	for i, pair := range pairs {
+		if opts.DefaultCert != "" && filepath.Clean(pair.CertPath) == filepath.Clean(opts.DefaultCert) {
+			return &pairs[i]
+		}
+
+		if opts.DefaultDomain == "" || pair.Cert.VerifyHostname(opts.DefaultDomain) != nil {
+			continue
+		}
+
+		if defaultPair == nil || pair.Cert.NotAfter.After(defaultPair.Cert.NotAfter) {
+			defaultPair = &pairs[i]
+		}
+	}
+
+	if defaultPair == nil && (opts.DefaultCert != "" || opts.DefaultDomain != "") {
+		log.WithFields(log.Fields{"path": opts.DefaultCert, "domain": opts.DefaultDomain}).Warn("No pair found for default certificate")
+	}
+
+	return defaultPair
+}
+
+// Merge replaces the autogenerated block in the previous content of the
+// config file, keeping everything outside of the markers. If there is no
+// block yet, it is appended to the existing content. Output without the
+// start marker, e.g. from user templates, replaces the whole file.
+func Merge(previous []byte, block []byte) []byte {
+	if !bytes.HasPrefix(block, []byte(ConfigHeader)) {
+		return block
+	}
+
+	start := bytes.Index(previous, []byte(ConfigHeader))
+	if start >= 0 {
+		end := bytes.Index(previous[start:], []byte(ConfigFooter))
+		if end >= 0 {
+			end += start + len(ConfigFooter)
+
+			merged := append([]byte{}, previous[:start]...)
+			merged = append(merged, block...)
+
+			return append(merged, previous[end:]...)
+		}
+	}
+
+	previous = bytes.TrimRight(previous, "\n")
+	if len(previous) == 0 {
+		return block
+	}
+
+	merged := append([]byte{}, previous...)
+	merged = append(merged, []byte("\n\n")...)
+	merged = append(merged, block...)
+
+	return append(merged, '\n')
+}
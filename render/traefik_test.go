@@ -0,0 +1,118 @@
+package render
+
+import (
+	"crypto/x509"
+	"testing"
+
+	"github.com/BurntSushi/toml"
+	"github.com/chrisxf/traefik-tls-config-gen/matcher"
+)
+
+func TestTomlString(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"plain", `"plain"`},
+		{"", `""`},
+		{`quo"te`, `"quo\"te"`},
+		{`C:\certs\a.crt`, `"C:\\certs\\a.crt"`},
+		{"tab\tnl\ncr\r", `"tab\tnl\ncr\r"`},
+		{"\b\f", `"\b\f"`},
+		{"nul\x00", `"nul\u0000"`},
+		{"esc\x1b", `"esc\u001B"`},
+		{"del\x7f", `"del\u007F"`},
+		{"ünïcødé 证书 😀", `"ünïcødé 证书 😀"`},
+	}
+
+	for _, test := range tests {
+		if got := tomlString(test.value); got != test.want {
+			t.Errorf("tomlString(%q) = %s, want %s", test.value, got, test.want)
+		}
+	}
+}
+
+func TestTomlKey(t *testing.T) {
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{"mtls", "mtls"},
+		{"client-auth_2", "client-auth_2"},
+		{"with.dot", `"with.dot"`},
+		{"with space", `"with space"`},
+		{`quo"te`, `"quo\"te"`},
+		{"", `""`},
+		{"ünïcødé", `"ünïcødé"`},
+		{"new\nline", `"new\nline"`},
+	}
+
+	for _, test := range tests {
+		if got := tomlKey(test.key); got != test.want {
+			t.Errorf("tomlKey(%q) = %s, want %s", test.key, got, test.want)
+		}
+	}
+}
+
+// quotedPaths are file names that must survive the TOML quoting unchanged.
+var quotedPaths = []string{
+	`/certs/quo"te.crt`,
+	`C:\certs\windows.crt`,
+	"/certs/new\nline.crt",
+	"/certs/nul\x00.crt",
+	"/certs/ünïcødé/证书.pem",
+	"/certs/#not a comment.crt",
+	"/certs/ = [x]\n[[tls.certificates]].crt",
+}
+
+type certFiles struct {
+	CertFile string `toml:"certFile"`
+	KeyFile  string `toml:"keyFile"`
+}
+
+func TestTraefikPaths(t *testing.T) {
+	for _, path := range quotedPaths {
+		pairs := []matcher.KeyPair{{Cert: &x509.Certificate{}, CertPath: path, KeyPath: path + ".key"}}
+		content := Traefik(pairs, &Options{TraefikVersion: 2})
+
+		var config struct {
+			TLS struct {
+				Certificates []certFiles `toml:"certificates"`
+			} `toml:"tls"`
+		}
+
+		if _, err := toml.Decode(string(content), &config); err != nil {
+			t.Errorf("config for %q does not parse: %v", path, err)
+			continue
+		}
+
+		want := certFiles{path, path + ".key"}
+		if len(config.TLS.Certificates) != 1 || config.TLS.Certificates[0] != want {
+			t.Errorf("certificates for %q = %q, want %q", path, config.TLS.Certificates, want)
+		}
+	}
+}
+
+func TestTraefikV1Paths(t *testing.T) {
+	for _, path := range quotedPaths {
+		pairs := []matcher.KeyPair{{Cert: &x509.Certificate{}, CertPath: path, KeyPath: path + ".key"}}
+		content := Traefik(pairs, &Options{TraefikVersion: 1, EntryPoints: []string{`quo"te`}})
+
+		var config struct {
+			TLS []struct {
+				EntryPoints []string  `toml:"entryPoints"`
+				Certificate certFiles `toml:"certificate"`
+			} `toml:"tls"`
+		}
+
+		if _, err := toml.Decode(string(content), &config); err != nil {
+			t.Errorf("config for %q does not parse: %v", path, err)
+			continue
+		}
+
+		want := certFiles{path, path + ".key"}
+		if len(config.TLS) != 1 || config.TLS[0].Certificate != want || config.TLS[0].EntryPoints[0] != `quo"te` {
+			t.Errorf("entries for %q = %q, want %q", path, config.TLS, want)
+		}
+	}
+}
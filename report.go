@@ -0,0 +1,126 @@
+package main
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/chrisxf/traefik-tls-config-gen/scanner"
+	"github.com/chrisxf/traefik-tls-config-gen/tlsconfig"
+)
+
+type report struct {
+	Time         time.Time       `json:"time"`
+	Duration     float64         `json:"durationSeconds"`
+	Error        string          `json:"error,omitempty"`
+	Files        int             `json:"filesScanned"`
+	Certs        int             `json:"certsFound"`
+	Keys         int             `json:"keysFound"`
+	PairsMatched int             `json:"pairsMatched"`
+	Pairs        int             `json:"pairs"`
+	Changed      bool            `json:"changed"`
+	Orphans      []reportOrphan  `json:"orphans"`
+	Expired      []reportExpired `json:"expired"`
+	ParseErrors  []reportError   `json:"parseErrors"`
+	// MissingDomains are the required domains no certificate covers.
+	MissingDomains []string `json:"missingDomains"`
+}
+
+type reportOrphan struct {
+	Path   string `json:"path"`
+	Reason string `json:"reason"`
+}
+
+type reportExpired struct {
+	Path       string    `json:"path"`
+	CommonName string    `json:"commonName"`
+	NotAfter   time.Time `json:"notAfter"`
+}
+
+type reportError struct {
+	Path  string `json:"path"`
+	Error string `json:"error"`
+}
+
+// newReport summarizes a run. Expired certificates are listed whether they
+// were dropped or included with --include-expired.
+func newReport(result tlsconfig.Result, err error) report {
+	r := report{
+		Time:           time.Now().UTC(),
+		Duration:       result.Duration.Seconds(),
+		Files:          result.Files,
+		Certs:          result.Certs,
+		Keys:           result.Keys,
+		PairsMatched:   len(result.Matched),
+		Pairs:          len(result.Pairs),
+		Changed:        result.Changed,
+		Orphans:        []reportOrphan{},
+		Expired:        []reportExpired{},
+		ParseErrors:    []reportError{},
+		MissingDomains: append([]string{}, result.MissingDomains...),
+	}
+
+	if err != nil {
+		r.Error = err.Error()
+	}
+
+	for _, orphan := range result.Orphans {
+		r.Orphans = append(r.Orphans, reportOrphan{Path: orphan.Path, Reason: orphan.Reason})
+	}
+
+	if result.Scanned == nil {
+		return r
+	}
+
+	addExpired := func(path string, cert *x509.Certificate) {
+		r.Expired = append(r.Expired, reportExpired{
+			Path:       path,
+			CommonName: cert.Subject.CommonName,
+			NotAfter:   cert.NotAfter.UTC(),
+		})
+	}
+
+	now := time.Now()
+
+	for _, pub := range append(result.Scanned.Certs, result.Scanned.Combined...) {
+		if pub.Cert.NotAfter.Before(now) {
+			addExpired(pub.Path, pub.Cert)
+		}
+	}
+
+	for _, fileErr := range result.Scanned.Errors {
+		if fileErr.Err == scanner.ErrExpired && fileErr.Cert != nil {
+			addExpired(fileErr.Path, fileErr.Cert)
+			continue
+		}
+
+		r.ParseErrors = append(r.ParseErrors, reportError{Path: fileErr.Path, Error: fileErr.Err.Error()})
+	}
+
+	sort.Slice(r.Expired, func(i, j int) bool {
+		return r.Expired[i].Path < r.Expired[j].Path
+	})
+
+	return r
+}
+
+// writeReport writes the JSON summary of a run to path, or to stdout if path
+// is "-".
+func writeReport(path string, result tlsconfig.Result, runErr error) error {
+	content, err := json.MarshalIndent(newReport(result, runErr), "", "  ")
+	if err != nil {
+		return err
+	}
+
+	content = append(content, '\n')
+
+	if path == "-" {
+		_, err = os.Stdout.Write(content)
+		return err
+	}
+
+	return ioutil.WriteFile(path, content, 0644)
+}
@@ -0,0 +1,105 @@
+package revocation
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// CRL is a certificate revocation list and the file or URL it was loaded
+// from.
+type CRL struct {
+	Source string
+	List   *x509.RevocationList
+}
+
+func readCRL(ctx context.Context, source string) ([]byte, error) {
+	if !strings.HasPrefix(source, "http://") && !strings.HasPrefix(source, "https://") {
+		return ioutil.ReadFile(source)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, source, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := (&http.Client{Timeout: 30 * time.Second}).Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New(source + ": " + resp.Status)
+	}
+
+	return ioutil.ReadAll(io.LimitReader(resp.Body, 64<<20))
+}
+
+// LoadCRLs reads the PEM or DER encoded CRLs from the files and http(s)
+// URLs in sources.
+func LoadCRLs(ctx context.Context, sources []string) ([]CRL, error) {
+	var crls []CRL
+
+	for _, source := range sources {
+		content, err := readCRL(ctx, source)
+		if err != nil {
+			return nil, err
+		}
+
+		if bytes.Contains(content, []byte("-----BEGIN X509 CRL-----")) {
+			block, _ := pem.Decode(content)
+			if block == nil {
+				return nil, errors.New(source + ": invalid PEM")
+			}
+
+			content = block.Bytes
+		}
+
+		list, err := x509.ParseRevocationList(content)
+		if err != nil {
+			return nil, errors.New(source + ": " + err.Error())
+		}
+
+		if !list.NextUpdate.IsZero() && time.Now().After(list.NextUpdate) {
+			log.WithFields(log.Fields{"crl": source, "nextUpdate": list.NextUpdate}).Warn("CRL is outdated")
+		}
+
+		crls = append(crls, CRL{Source: source, List: list})
+	}
+
+	return crls, nil
+}
+
+// CheckCRLs returns the entry of cert in the CRLs of its issuer, nil if it
+// is not revoked. The signature of the CRLs is checked if issuer is set.
+func CheckCRLs(crls []CRL, cert *x509.Certificate, issuer *x509.Certificate) *x509.RevocationListEntry {
+	for _, crl := range crls {
+		if !bytes.Equal(crl.List.RawIssuer, cert.RawIssuer) {
+			continue
+		}
+
+		if issuer != nil && crl.List.CheckSignatureFrom(issuer) != nil {
+			log.WithField("crl", crl.Source).Warn("CRL not signed by the issuer of the certificate")
+			continue
+		}
+
+		for i, entry := range crl.List.RevokedCertificateEntries {
+			if entry.SerialNumber.Cmp(cert.SerialNumber) == 0 {
+				return &crl.List.RevokedCertificateEntries[i]
+			}
+		}
+	}
+
+	return nil
+}
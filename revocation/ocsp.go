@@ -0,0 +1,112 @@
+// Package revocation checks whether certificates were revoked by their CA.
+package revocation
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/crypto/ocsp"
+)
+
+// OCSPClient fetches OCSP responses. Good responses are cached in CacheDir,
+// if set, until halfway to their next update, so staples written from them
+// are refreshed in time.
+type OCSPClient struct {
+	CacheDir string
+	HTTP     *http.Client
+}
+
+// NewOCSPClient returns a client caching in cacheDir.
+func NewOCSPClient(cacheDir string) *OCSPClient {
+	return &OCSPClient{CacheDir: cacheDir, HTTP: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (c *OCSPClient) cachePath(cert *x509.Certificate) string {
+	hash := sha256.Sum256(cert.Raw)
+	return filepath.Join(c.CacheDir, hex.EncodeToString(hash[:])+".ocsp")
+}
+
+// current reports whether the response is not past the middle of its
+// validity. A response without next update is considered current for an
+// hour.
+func current(response *ocsp.Response) bool {
+	if response.NextUpdate.IsZero() {
+		return time.Since(response.ThisUpdate) < time.Hour
+	}
+
+	return time.Now().Before(response.ThisUpdate.Add(response.NextUpdate.Sub(response.ThisUpdate) / 2))
+}
+
+// Fetch returns the parsed and the DER encoded OCSP response for cert,
+// issued by issuer, from the cache or the first responder of cert.
+func (c *OCSPClient) Fetch(ctx context.Context, cert *x509.Certificate, issuer *x509.Certificate) (*ocsp.Response, []byte, error) {
+	if len(cert.OCSPServer) == 0 {
+		return nil, nil, errors.New("certificate has no OCSP responder")
+	}
+
+	if c.CacheDir != "" {
+		if raw, err := ioutil.ReadFile(c.cachePath(cert)); err == nil {
+			response, err := ocsp.ParseResponseForCert(raw, cert, issuer)
+			if err == nil && current(response) {
+				return response, raw, nil
+			}
+		}
+	}
+
+	request, err := ocsp.CreateRequest(cert, issuer, &ocsp.RequestOptions{Hash: crypto.SHA1})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	url := cert.OCSPServer[0]
+	log.WithField("url", url).Debug("Querying OCSP responder")
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(request))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/ocsp-request")
+	req.Header.Set("Accept", "application/ocsp-response")
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	defer resp.Body.Close()
+
+	raw, err := ioutil.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, errors.New("OCSP responder " + url + ": " + resp.Status)
+	}
+
+	response, err := ocsp.ParseResponseForCert(raw, cert, issuer)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if c.CacheDir != "" && response.Status == ocsp.Good {
+		if err := os.MkdirAll(c.CacheDir, 0755); err == nil {
+			ioutil.WriteFile(c.cachePath(cert), raw, 0644)
+		}
+	}
+
+	return response, raw, nil
+}
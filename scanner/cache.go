@@ -0,0 +1,208 @@
+package scanner
+
+import (
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const cacheVersion = 1
+
+type cacheEntry struct {
+	Size        int64     `json:"size"`
+	ModTime     time.Time `json:"modTime"`
+	Fingerprint string    `json:"fingerprint,omitempty"`
+	Invalid     bool      `json:"invalid,omitempty"`
+	KeyType     PEMType   `json:"type,omitempty"`
+	PublicKey   []byte    `json:"publicKey,omitempty"`
+	Chain       [][]byte  `json:"chain,omitempty"`
+}
+
+type cacheFile struct {
+	Version int                    `json:"version"`
+	Files   map[string]*cacheEntry `json:"files"`
+}
+
+// Cache remembers the results of loading files between runs, so files whose
+// size and modification time did not change are not read again. Only
+// certificates and keys loaded from the file itself and files that are
+// neither are cached, errors and converted DER and PKCS#12 files are not.
+type Cache struct {
+	path string
+
+	mu    sync.Mutex
+	files map[string]*cacheEntry
+	used  map[string]*cacheEntry
+}
+
+// LoadCache reads the state file at path. A missing or unreadable state file
+// results in an empty cache.
+func LoadCache(path string) *Cache {
+	cache := &Cache{path: path, files: map[string]*cacheEntry{}, used: map[string]*cacheEntry{}}
+
+	content, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return cache
+	}
+
+	state := &cacheFile{}
+	if err == nil {
+		err = json.Unmarshal(content, state)
+	}
+
+	if err != nil || state.Version != cacheVersion {
+		log.WithFields(log.Fields{"path": path, "error": err}).Warn("Ignoring unusable state file")
+		return cache
+	}
+
+	if state.Files != nil {
+		cache.files = state.Files
+	}
+
+	return cache
+}
+
+// lookup returns the cached result for path if the file did not change. The
+// expiry of cached certificates is checked again.
+func (c *Cache) lookup(path string, info os.FileInfo, opts *Options) (PublicKey, bool, error) {
+	c.mu.Lock()
+	entry := c.files[path]
+	c.mu.Unlock()
+
+	if entry == nil || entry.Size != info.Size() || !entry.ModTime.Equal(info.ModTime()) {
+		return PublicKey{}, false, nil
+	}
+
+	c.mu.Lock()
+	c.used[path] = entry
+	c.mu.Unlock()
+
+	if entry.Invalid {
+		return PublicKey{}, true, ErrInvalidFile
+	}
+
+	key, err := x509.ParsePKIXPublicKey(entry.PublicKey)
+	if err != nil {
+		return PublicKey{}, false, nil
+	}
+
+	pubKey := PublicKey{Path: path, KeyPath: path, Key: key, KeyType: entry.KeyType}
+
+	for _, der := range entry.Chain {
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			return PublicKey{}, false, nil
+		}
+
+		pubKey.Chain = append(pubKey.Chain, cert)
+	}
+
+	if entry.KeyType != PKey {
+		if len(pubKey.Chain) == 0 {
+			return PublicKey{}, false, nil
+		}
+
+		pubKey.Cert = pubKey.Chain[0]
+
+		if pubKey.Cert.NotAfter.Before(time.Now()) {
+			if err := handleExpired(path, ErrExpired, opts); err != nil {
+				return pubKey, true, err
+			}
+		}
+	}
+
+	log.WithField("path", path).Debug("Unchanged, using cached result")
+
+	return pubKey, true, nil
+}
+
+// store remembers the result of loading path.
+func (c *Cache) store(path string, info os.FileInfo, pubKey PublicKey, err error) {
+	entry := &cacheEntry{Size: info.Size(), ModTime: info.ModTime()}
+
+	switch {
+	case err == ErrInvalidFile:
+		entry.Invalid = true
+	case err != nil || pubKey.Path != path || pubKey.KeyPath != path:
+		return
+	default:
+		der, err := x509.MarshalPKIXPublicKey(pubKey.Key)
+		if err != nil {
+			return
+		}
+
+		fingerprint, _ := KeyFingerprint(pubKey.Key)
+
+		entry.KeyType = pubKey.KeyType
+		entry.PublicKey = der
+		entry.Fingerprint = hex.EncodeToString(fingerprint[:])
+
+		if pubKey.Cert != nil {
+			entry.Chain = append(entry.Chain, pubKey.Cert.Raw)
+		}
+
+		for _, cert := range pubKey.Chain {
+			if pubKey.Cert == nil || !cert.Equal(pubKey.Cert) {
+				entry.Chain = append(entry.Chain, cert.Raw)
+			}
+		}
+	}
+
+	c.mu.Lock()
+	c.used[path] = entry
+	c.mu.Unlock()
+}
+
+// Save writes the entries of all files seen since the cache was loaded to the
+// state file, dropping those of files that are gone.
+func (c *Cache) Save() error {
+	c.mu.Lock()
+	content, err := json.Marshal(&cacheFile{Version: cacheVersion, Files: c.used})
+	c.mu.Unlock()
+
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(c.path), 0755)
+	if err != nil {
+		return err
+	}
+
+	tmp := c.path + ".tmp"
+
+	err = ioutil.WriteFile(tmp, content, 0644)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, c.path)
+}
+
+// loadFile loads path through the cache of opts, if there is one.
+func loadFile(path string, opts *Options) (PublicKey, error) {
+	if opts.Cache == nil {
+		return LoadFile(path, opts)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return LoadFile(path, opts)
+	}
+
+	if pubKey, ok, err := opts.Cache.lookup(path, info, opts); ok {
+		return pubKey, err
+	}
+
+	pubKey, err := LoadFile(path, opts)
+	opts.Cache.store(path, info, pubKey, err)
+
+	return pubKey, err
+}
@@ -0,0 +1,56 @@
+package scanner
+
+import (
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func isDER(content []byte) bool {
+	var value asn1.RawValue
+
+	rest, err := asn1.Unmarshal(content, &value)
+
+	return err == nil && len(rest) == 0 && value.Tag == asn1.TagSequence && value.IsCompound
+}
+
+// convertDERFile returns the PEM form of a DER encoded certificate or private
+// key as the managed file in the staging directory, without writing it.
+func convertDERFile(path string, content []byte, opts *Options) (ManagedFile, error) {
+	var blockType string
+	var ext string
+
+	if _, err := x509.ParseCertificate(content); err == nil {
+		blockType, ext = "CERTIFICATE", ".crt"
+	} else if _, err := x509.ParsePKCS8PrivateKey(content); err == nil {
+		blockType, ext = "PRIVATE KEY", ".key"
+	} else if _, err := x509.ParsePKCS1PrivateKey(content); err == nil {
+		blockType, ext = "RSA PRIVATE KEY", ".key"
+	} else if _, err := x509.ParseECPrivateKey(content); err == nil {
+		blockType, ext = "EC PRIVATE KEY", ".key"
+	} else {
+		return ManagedFile{}, ErrInvalidFile
+	}
+
+	if opts.DERDir == "" {
+		log.WithField("path", path).Warn("Skipping DER file, no staging directory set")
+		return ManagedFile{}, errors.New("no der staging directory")
+	}
+
+	var perm os.FileMode = 0644
+	if ext == ".key" {
+		perm = 0600
+	}
+
+	pemContent := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: content})
+	pemPath := filepath.Join(opts.DERDir, ManagedFileName(path, content)+ext)
+
+	log.WithFields(log.Fields{"path": path, "pemFile": pemPath}).Info("DER file")
+
+	return ManagedFile{Path: pemPath, Content: pemContent, Perm: perm}, nil
+}
@@ -0,0 +1,109 @@
+package scanner
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+)
+
+// IgnoreFile is the name of the files listing paths FindFiles skips, using
+// gitignore syntax. Patterns are relative to the directory of the file.
+const IgnoreFile = ".certignore"
+
+type ignoreRule struct {
+	// dir is the directory of the ignore file, relative to the walk base.
+	dir      string
+	pattern  string
+	negate   bool
+	dirOnly  bool
+	anchored bool
+}
+
+type ignoreRules []ignoreRule
+
+// loadIgnoreFile appends the rules of the ignore file in dir, if there is one.
+func loadIgnoreFile(base string, dir string, rules ignoreRules) (ignoreRules, error) {
+	content, err := ioutil.ReadFile(path.Join(base, dir, IgnoreFile))
+	if os.IsNotExist(err) {
+		return rules, nil
+	}
+
+	if err != nil {
+		return rules, err
+	}
+
+	// Copy so sibling directories do not share the appended rules.
+	rules = append(ignoreRules{}, rules...)
+
+	for _, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimRight(line, "\r")
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		rule := ignoreRule{dir: dir}
+
+		if strings.HasPrefix(line, "!") {
+			rule.negate = true
+			line = line[1:]
+		} else if strings.HasPrefix(line, "\\") {
+			line = line[1:]
+		}
+
+		line = strings.TrimRight(line, " ")
+
+		if strings.HasSuffix(line, "/") {
+			rule.dirOnly = true
+			line = strings.TrimRight(line, "/")
+		}
+
+		if strings.Contains(line, "/") {
+			rule.anchored = true
+			line = strings.TrimLeft(line, "/")
+		}
+
+		if line == "" {
+			continue
+		}
+
+		rule.pattern = line
+		rules = append(rules, rule)
+	}
+
+	return rules, nil
+}
+
+// ignored reports whether rel, relative to the walk base, is ignored. Like in
+// git, the last matching rule wins and rules of deeper files come last.
+func (rules ignoreRules) ignored(rel string, isDir bool) bool {
+	ignored := false
+
+	for _, rule := range rules {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+
+		name := rel
+		if rule.dir != "" {
+			if !strings.HasPrefix(rel, rule.dir+"/") {
+				continue
+			}
+
+			name = strings.TrimPrefix(rel, rule.dir+"/")
+		}
+
+		var match bool
+		if rule.anchored {
+			match = matchSegments(strings.Split(rule.pattern, "/"), strings.Split(name, "/"))
+		} else {
+			match, _ = path.Match(rule.pattern, path.Base(name))
+		}
+
+		if match {
+			ignored = !rule.negate
+		}
+	}
+
+	return ignored
+}
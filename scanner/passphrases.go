@@ -0,0 +1,68 @@
+package scanner
+
+import (
+	"errors"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Passphrases holds the passphrases used to decrypt encrypted private keys.
+// Files are tried in order, Fallback is used for all other files.
+type Passphrases struct {
+	Fallback []byte
+	Files    []PassphraseEntry
+}
+
+// PassphraseEntry is the passphrase of the key files matching a glob pattern.
+type PassphraseEntry struct {
+	Pattern    string
+	Passphrase []byte
+}
+
+// ParsePassphraseMap parses a mapping file with one "pattern=passphrase"
+// entry per line, keeping their order. Empty lines and lines starting with #
+// are ignored.
+func ParsePassphraseMap(content []byte) ([]PassphraseEntry, error) {
+	var files []PassphraseEntry
+
+	for i, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) != 2 {
+			return nil, errors.New("invalid passphrase mapping in line " + strconv.Itoa(i+1))
+		}
+
+		files = append(files, PassphraseEntry{
+			Pattern:    strings.TrimSpace(parts[0]),
+			Passphrase: []byte(strings.TrimSpace(parts[1])),
+		})
+	}
+
+	return files, nil
+}
+
+// Lookup returns the passphrase for the given key file. Mapping entries are
+// matched as glob patterns against both the full path and the file name, the
+// first matching entry wins.
+func (p *Passphrases) Lookup(path string) []byte {
+	if p == nil {
+		return nil
+	}
+
+	for _, entry := range p.Files {
+		if ok, _ := filepath.Match(entry.Pattern, path); ok {
+			return entry.Passphrase
+		}
+
+		if ok, _ := filepath.Match(entry.Pattern, filepath.Base(path)); ok {
+			return entry.Passphrase
+		}
+	}
+
+	return p.Fallback
+}
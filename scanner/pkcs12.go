@@ -0,0 +1,93 @@
+package scanner
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"path/filepath"
+
+	log "github.com/sirupsen/logrus"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// isPKCS12Error reports whether err of pkcs12.DecodeChain is about a
+// PKCS#12 bundle that could not be read, rather than about content that is
+// no bundle at all.
+func isPKCS12Error(err error) bool {
+	if _, ok := err.(pkcs12.NotImplementedError); ok {
+		return true
+	}
+
+	return err == pkcs12.ErrIncorrectPassword || err == pkcs12.ErrDecryption
+}
+
+// loadPKCS12File extracts certificate chain and private key of a PKCS#12
+// bundle into PEM files inside the configured output directory, which are
+// returned as managed files instead of being written.
+func loadPKCS12File(path string, content []byte, opts *Options) (PublicKey, error) {
+	var pubKey PublicKey
+
+	pkey, leaf, chain, err := pkcs12.DecodeChain(content, opts.P12Password)
+	if err != nil && !isPKCS12Error(err) {
+		log.WithFields(log.Fields{"path": path, "error": err}).Debug("Skipping file that is no PKCS#12 bundle")
+		return pubKey, ErrInvalidFile
+	}
+
+	if err != nil {
+		log.WithFields(log.Fields{"path": path, "error": err}).Error("Could not decode PKCS#12 file")
+		return pubKey, err
+	}
+
+	if opts.P12Dir == "" {
+		log.WithField("path", path).Warn("Skipping PKCS#12 file, no output directory set")
+		return pubKey, errors.New("no pkcs12 output directory")
+	}
+
+	certBuf := &bytes.Buffer{}
+
+	for _, cert := range append([]*x509.Certificate{leaf}, chain...) {
+		pem.Encode(certBuf, &pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+	}
+
+	key, cert, err := getCertAndPubKeyFromCert(certBuf.Bytes())
+	err = handleExpired(path, err, opts)
+	if err != nil {
+		return pubKey, err
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(pkey)
+	if err != nil {
+		return pubKey, err
+	}
+
+	keyBytes := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+
+	privateKeyPub, err := getPubKeyFromPKey(keyBytes, nil)
+	if err != nil {
+		return pubKey, err
+	}
+
+	if !PublicKeysEqual(key, privateKeyPub) {
+		return pubKey, errors.New("certificate and private key do not match")
+	}
+
+	name := ManagedFileName(path, cert.Raw)
+	certPath := filepath.Join(opts.P12Dir, name+".crt")
+	keyPath := filepath.Join(opts.P12Dir, name+".key")
+
+	log.WithFields(log.Fields{"path": path, "certFile": certPath, "keyFile": keyPath}).Info("PKCS#12 bundle")
+
+	return PublicKey{
+		Key:     key,
+		Path:    certPath,
+		KeyPath: keyPath,
+		Cert:    cert,
+		Chain:   append([]*x509.Certificate{leaf}, chain...),
+		KeyType: Combined,
+		Managed: []ManagedFile{
+			{Path: certPath, Content: certBuf.Bytes(), Perm: 0644},
+			{Path: keyPath, Content: keyBytes, Perm: 0600},
+		},
+	}, nil
+}
@@ -0,0 +1,327 @@
+// Package scanner finds certificates and private keys on disk and extracts
+// the public keys needed to match them.
+package scanner
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/x509"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type PEMType string
+
+const (
+	Cert     PEMType = "cert"
+	PKey     PEMType = "pkey"
+	Combined PEMType = "combined"
+)
+
+var (
+	ErrExpired     = errors.New("expired")
+	ErrInvalidFile = errors.New("invalid file")
+)
+
+// PublicKey is a certificate or private key found during the scan along with
+// its parsed public key. For combined files, KeyPath points at the file
+// holding the private key.
+type PublicKey struct {
+	Path    string
+	KeyPath string
+	Key     crypto.PublicKey
+	Cert    *x509.Certificate
+	Chain   []*x509.Certificate
+	KeyType PEMType
+	// Managed are the converted files Path and KeyPath refer to, which are
+	// not written by the scanner.
+	Managed []ManagedFile
+}
+
+type Options struct {
+	Passphrases    *Passphrases
+	P12Password    string
+	P12Dir         string
+	DERDir         string
+	IncludeExpired bool
+	// Concurrency is the number of files loaded in parallel, NumCPU if
+	// zero.
+	Concurrency int
+	// Cache, if set, is used to skip files that did not change.
+	Cache *Cache
+	// MaxFileSize is the size in bytes above which files are skipped
+	// without reading them, no limit if zero.
+	MaxFileSize int64
+}
+
+// sniffSize is the number of bytes checked for binary content.
+const sniffSize = 512
+
+// FileError is an error loading a single file.
+type FileError struct {
+	Path string
+	Err  error
+	// Cert is the certificate of the file if it could be parsed anyway,
+	// e.g. for ErrExpired.
+	Cert *x509.Certificate
+}
+
+func (e *FileError) Error() string {
+	return e.Path + ": " + e.Err.Error()
+}
+
+type Result struct {
+	Certs    []PublicKey
+	Keys     []PublicKey
+	Combined []PublicKey
+	// Errors holds the files that could not be loaded. Files that are
+	// neither certificates nor keys are not included, expired certificates
+	// are with ErrExpired unless Options.IncludeExpired is set.
+	Errors []*FileError
+	// Managed are the converted files of all certificates and keys.
+	Managed []ManagedFile
+}
+
+type publicKeyResult struct {
+	path string
+	res  PublicKey
+	err  error
+}
+
+// CertLogger returns a logger carrying the metadata of the given certificate.
+func CertLogger(path string, cert *x509.Certificate) *log.Entry {
+	fields := log.Fields{"path": path}
+
+	if cert != nil {
+		fields["domain"] = cert.Subject.CommonName
+		fields["notAfter"] = cert.NotAfter.Format(time.RFC3339)
+	}
+
+	return log.WithFields(fields)
+}
+
+// handleExpired logs expired certificates and drops ErrExpired if expired
+// certificates should be included anyway.
+func handleExpired(path string, err error, opts *Options) error {
+	if err != ErrExpired {
+		return err
+	}
+
+	if opts.IncludeExpired {
+		log.WithField("path", path).Warn("Including expired certificate")
+		return nil
+	}
+
+	log.WithField("path", path).Warn("Found expired certificate")
+
+	return err
+}
+
+// LoadFile parses a single certificate, private key, combined, PKCS#12 or DER
+// file. Files that contain neither return ErrInvalidFile.
+func LoadFile(path string, opts *Options) (PublicKey, error) {
+	var pubKey PublicKey
+
+	file, err := os.Open(path)
+	if err != nil {
+		log.WithFields(log.Fields{"path": path, "error": err}).Error("Could not open file")
+		return pubKey, err
+	}
+
+	defer file.Close()
+
+	if opts.MaxFileSize > 0 {
+		if info, err := file.Stat(); err == nil && info.Size() > opts.MaxFileSize {
+			log.WithFields(log.Fields{"path": path, "size": info.Size()}).Debug("Skipping file exceeding max file size")
+			return pubKey, ErrInvalidFile
+		}
+	}
+
+	reader := bufio.NewReaderSize(file, sniffSize)
+
+	head, _ := reader.Peek(sniffSize)
+	if isBinary(head) {
+		log.WithField("path", path).Debug("Skipping binary file")
+		return pubKey, ErrInvalidFile
+	}
+
+	content, err := ioutil.ReadAll(reader)
+	if err != nil {
+		log.WithFields(log.Fields{"path": path, "error": err}).Error("Could not read file")
+		return pubKey, err
+	}
+
+	ext := strings.ToLower(filepath.Ext(path))
+
+	if ext == ".p12" || ext == ".pfx" {
+		return loadPKCS12File(path, content, opts)
+	}
+
+	if ext == ".ocsp" {
+		log.WithField("path", path).Debug("Skipping OCSP response")
+		return pubKey, ErrInvalidFile
+	}
+
+	var managed []ManagedFile
+
+	if isDER(content) {
+		file, err := convertDERFile(path, content, opts)
+		if err != nil {
+			return pubKey, err
+		}
+
+		path, content, managed = file.Path, file.Content, []ManagedFile{file}
+	}
+
+	var key crypto.PublicKey
+	var cert *x509.Certificate
+	var keyType PEMType = Cert
+
+	if bytes.Contains(content, []byte(PubHeader)) && containsPrivateKey(content) {
+		key, cert, err = getCertAndPubKeyFromCert(content)
+		err = handleExpired(path, err, opts)
+		keyType = Combined
+
+		if err == nil {
+			var privateKeyPub crypto.PublicKey
+
+			privateKeyPub, err = getPubKeyFromPKey(content, opts.Passphrases.Lookup(path))
+			if err == nil && !PublicKeysEqual(key, privateKeyPub) {
+				err = errors.New("certificate and private key do not match")
+			}
+		}
+
+		if err == nil {
+			CertLogger(path, cert).Info("Combined certificate and private key")
+		}
+	} else if bytes.Contains(content, []byte(PubHeader)) {
+		key, cert, err = getCertAndPubKeyFromCert(content)
+		err = handleExpired(path, err, opts)
+
+		if err == nil {
+			CertLogger(path, cert).Info("Certificate")
+		}
+	} else if containsPrivateKey(content) {
+		key, err = getPubKeyFromPKey(content, opts.Passphrases.Lookup(path))
+		keyType = PKey
+
+		log.WithField("path", path).Info("Private key")
+	} else {
+		return pubKey, ErrInvalidFile
+	}
+
+	if err != nil {
+		log.WithFields(log.Fields{"path": path, "error": err}).Warn("Could not load public key from cert or private key")
+		return PublicKey{Path: path, Cert: cert}, err
+	}
+
+	return PublicKey{
+		Key:     key,
+		Path:    path,
+		KeyPath: path,
+		Cert:    cert,
+		Chain:   ParseCertificates(content),
+		KeyType: keyType,
+		Managed: managed,
+	}, nil
+}
+
+// isBinary reports whether head is the start of a binary file that can be
+// neither PEM nor DER. PEM files never contain NUL bytes and DER encoded
+// certificates, keys and PKCS#12 files start with a SEQUENCE tag.
+func isBinary(head []byte) bool {
+	return len(head) > 0 && head[0] != 0x30 && bytes.IndexByte(head, 0) >= 0
+}
+
+// Scan loads all files with a bounded number of workers and sorts the
+// results by type and path. If ctx is done, no further files are loaded and
+// the context error is returned along with the partial result.
+func Scan(ctx context.Context, files []string, opts *Options) (*Result, error) {
+	result := &Result{}
+
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = runtime.NumCPU()
+	}
+
+	paths := make(chan string)
+	c := make(chan publicKeyResult)
+
+	wg := &sync.WaitGroup{}
+
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for path := range paths {
+				pubKey, err := loadFile(path, opts)
+				c <- publicKeyResult{path: path, res: pubKey, err: err}
+			}
+		}()
+	}
+
+	go func() {
+		defer close(paths)
+
+		for _, path := range files {
+			select {
+			case paths <- path:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	go func() {
+		wg.Wait()
+		close(c)
+	}()
+
+	for pubKeyResult := range c {
+		if pubKeyResult.err != nil {
+			if pubKeyResult.err != ErrInvalidFile {
+				result.Errors = append(result.Errors, &FileError{Path: pubKeyResult.path, Err: pubKeyResult.err, Cert: pubKeyResult.res.Cert})
+			}
+
+			continue
+		}
+
+		result.Managed = append(result.Managed, pubKeyResult.res.Managed...)
+
+		switch pubKeyResult.res.KeyType {
+		case Cert:
+			result.Certs = append(result.Certs, pubKeyResult.res)
+		case Combined:
+			result.Combined = append(result.Combined, pubKeyResult.res)
+		default:
+			result.Keys = append(result.Keys, pubKeyResult.res)
+		}
+	}
+
+	log.WithFields(log.Fields{"certs": len(result.Certs), "keys": len(result.Keys), "combined": len(result.Combined)}).Info("Found certificates and private keys")
+
+	for _, files := range [][]PublicKey{result.Certs, result.Keys, result.Combined} {
+		sort.Slice(files, func(i, j int) bool {
+			return files[i].Path < files[j].Path
+		})
+	}
+
+	sort.Slice(result.Errors, func(i, j int) bool {
+		return result.Errors[i].Path < result.Errors[j].Path
+	})
+
+	return result, ctx.Err()
+}
@@ -0,0 +1,143 @@
+package scanner
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// WalkOptions restrict which files FindFiles returns. Patterns are matched
+// with MatchGlob against the path relative to the base directory.
+type WalkOptions struct {
+	// Include, if set, only returns files matching one of the patterns.
+	Include []string
+	// Exclude skips files and directories matching one of the patterns.
+	Exclude []string
+	// FollowSymlinks descends into symlinked directories. Directories
+	// already visited are skipped, which protects against loops.
+	FollowSymlinks bool
+	// MaxDepth limits how many directory levels are searched, files
+	// directly in base are at depth 1. Zero means no limit.
+	MaxDepth int
+}
+
+type walker struct {
+	ctx     context.Context
+	base    string
+	opts    *WalkOptions
+	visited []os.FileInfo
+	files   *[]string
+}
+
+// FindFiles appends all files below base to files, skipping those listed in
+// ignore files. Symlinked files are always followed. The walk stops early if
+// ctx is done.
+func FindFiles(ctx context.Context, base string, opts *WalkOptions, files *[]string) error {
+	if opts == nil {
+		opts = &WalkOptions{}
+	}
+
+	w := &walker{ctx: ctx, base: base, opts: opts, files: files}
+
+	info, err := os.Stat(base)
+	if err != nil {
+		return err
+	}
+
+	w.visited = append(w.visited, info)
+
+	return w.walk("", 1, nil)
+}
+
+// seen reports whether the directory was visited before and marks it as
+// visited otherwise.
+func (w *walker) seen(info os.FileInfo) bool {
+	for _, visited := range w.visited {
+		if os.SameFile(visited, info) {
+			return true
+		}
+	}
+
+	w.visited = append(w.visited, info)
+
+	return false
+}
+
+func (w *walker) walk(rel string, depth int, rules ignoreRules) error {
+	if err := w.ctx.Err(); err != nil {
+		return err
+	}
+
+	dir := path.Join(w.base, rel)
+
+	log.WithField("path", dir).Debug("Searching for certificates")
+
+	items, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	rules, err = loadIgnoreFile(w.base, rel, rules)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range items {
+		filePath := path.Join(dir, file.Name())
+		fileRel := path.Join(rel, file.Name())
+
+		if file.Name() == IgnoreFile {
+			continue
+		}
+
+		if file.Mode()&os.ModeSymlink != 0 {
+			target, err := os.Stat(filePath)
+			if err != nil {
+				log.WithFields(log.Fields{"path": filePath, "error": err}).Debug("Skipping broken symlink")
+				continue
+			}
+
+			if target.IsDir() && !w.opts.FollowSymlinks {
+				log.WithField("path", filePath).Debug("Skipping symlinked directory")
+				continue
+			}
+
+			file = target
+		}
+
+		if rules.ignored(fileRel, file.IsDir()) {
+			log.WithField("path", filePath).Debug("Ignored")
+			continue
+		}
+
+		if matchAny(w.opts.Exclude, fileRel) {
+			log.WithField("path", filePath).Debug("Excluded")
+			continue
+		}
+
+		if file.IsDir() {
+			if w.opts.MaxDepth > 0 && depth >= w.opts.MaxDepth {
+				log.WithField("path", filePath).Debug("Maximum depth reached")
+				continue
+			}
+
+			if w.seen(file) {
+				log.WithField("path", filePath).Debug("Skipping directory visited before")
+				continue
+			}
+
+			w.walk(fileRel, depth+1, rules)
+
+			if err := w.ctx.Err(); err != nil {
+				return err
+			}
+		} else if len(w.opts.Include) == 0 || matchAny(w.opts.Include, fileRel) {
+			*w.files = append(*w.files, filePath)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/chrisxf/traefik-tls-config-gen/render"
+	"github.com/chrisxf/traefik-tls-config-gen/tlsconfig"
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli"
+)
+
+// configServer serves the latest generated config to Traefik's HTTP
+// provider, or as REST SDS to Envoy with format envoy-sds.
+type configServer struct {
+	token  []byte
+	format string
+
+	mu      sync.RWMutex
+	content []byte
+}
+
+func (s *configServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Envoy posts its discovery requests, the response does not depend on
+	// them though.
+	if r.Method != http.MethodGet && r.Method != http.MethodHead && (r.Method != http.MethodPost || s.format != "envoy-sds") {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if s.token != nil {
+		auth := []byte(r.Header.Get("Authorization"))
+		if subtle.ConstantTimeCompare(auth, append([]byte("Bearer "), s.token...)) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+	}
+
+	s.mu.RLock()
+	content := s.content
+	s.mu.RUnlock()
+
+	if content == nil {
+		http.Error(w, "config not generated yet", http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(content)
+}
+
+// refresh generates the config and replaces the served one. The previous
+// config is kept if the run fails.
+func (s *configServer) refresh(ctx context.Context, c *cli.Context) (tlsconfig.Result, error) {
+	opts, err := loadOptions(c)
+	if err != nil {
+		return tlsconfig.Result{}, err
+	}
+
+	opts.Format = s.format
+	opts.Out = ""
+	// Not a dry run, the served config refers to the converted files and
+	// copies, which have to be written.
+	opts.ReturnContent = true
+
+	// The HTTP provider only exists since Traefik v2.
+	if opts.Format == "traefik-json" && opts.Render.TraefikVersion < 2 {
+		opts.Render.TraefikVersion = 2
+	}
+
+	ctx, cancel := runContext(ctx, c)
+	defer cancel()
+
+	result, err := tlsconfig.Generate(ctx, opts)
+	if err != nil {
+		return result, err
+	}
+
+	content := result.Content
+	if content == nil {
+		renderer, err := render.New(opts.Format, &opts.Render)
+		if err != nil {
+			return result, err
+		}
+
+		content, err = renderer.Render(nil)
+		if err != nil {
+			return result, err
+		}
+	}
+
+	s.mu.Lock()
+	if !bytes.Equal(s.content, content) {
+		log.WithField("pairs", len(result.Pairs)).Info("Serving new config")
+	}
+	s.content = content
+	s.mu.Unlock()
+
+	return result, nil
+}
+
+// serve regenerates the config in a fixed interval and serves it over HTTP
+// until ctx is done.
+func serve(ctx context.Context, c *cli.Context) {
+	s := &configServer{format: c.String("serve-format")}
+
+	switch s.format {
+	case "traefik-json", "envoy-sds":
+	default:
+		log.Fatal("unknown serve format " + s.format)
+	}
+
+	if path := c.String("token-file"); path != "" {
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		s.token = bytes.TrimRight(content, "\r\n")
+	}
+
+	metrics := newMetrics()
+
+	if addr := c.String("metrics-addr"); addr != "" {
+		go func() {
+			log.WithField("addr", addr).Info("Serving metrics")
+			log.Fatal(metrics.serve(addr))
+		}()
+	}
+
+	server := &http.Server{Addr: c.String("listen"), Handler: s}
+
+	go func() {
+		var err error
+
+		log.WithField("addr", server.Addr).Info("Serving config")
+
+		if c.IsSet("tls-cert") || c.IsSet("tls-key") {
+			err = server.ListenAndServeTLS(c.String("tls-cert"), c.String("tls-key"))
+		} else {
+			err = server.ListenAndServe()
+		}
+
+		if err != http.ErrServerClosed {
+			log.Fatal(err)
+		}
+	}()
+
+	for ctx.Err() == nil {
+		result, err := s.refresh(ctx, c)
+		if ctx.Err() != nil {
+			break
+		}
+
+		if err != nil {
+			log.WithError(err).Error("Config generation failed")
+		}
+
+		metrics.update(result, err)
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(c.Duration("watch-interval")):
+		}
+	}
+
+	log.Info("Stopping")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	server.Shutdown(shutdownCtx)
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"os"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli"
+)
+
+const defaultServiceName = "traefik-tls-config-gen"
+
+// serviceFlags name the Windows service.
+var serviceFlags = []cli.Flag{
+	cli.StringFlag{
+		Name:  "service-name",
+		Value: defaultServiceName,
+		Usage: "Name of the Windows service when run by the service manager",
+	},
+}
+
+// flagValue returns the value of the flag name in the unparsed args, or def.
+func flagValue(args []string, name string, def string) string {
+	for i, arg := range args {
+		arg = "-" + strings.TrimLeft(arg, "-")
+
+		if arg == "-"+name && i+1 < len(args) {
+			return args[i+1]
+		}
+
+		if strings.HasPrefix(arg, "-"+name+"=") {
+			return strings.TrimPrefix(arg, "-"+name+"=")
+		}
+	}
+
+	return def
+}
+
+// runServiceInstall registers the watch command with the arguments as
+// Windows service.
+func runServiceInstall(c *cli.Context) {
+	exe, err := os.Executable()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	args := append([]string{"watch"}, c.Args()...)
+	name := flagValue(c.Args(), "service-name", defaultServiceName)
+
+	err = installService(name, exe, args)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	log.WithField("service", name).Info("Installed service")
+}
+
+func runServiceRemove(c *cli.Context) {
+	name := c.String("service-name")
+
+	err := removeService(name)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	log.WithField("service", name).Info("Removed service")
+}
+
+var serviceCommand = cli.Command{
+	Name:  "service",
+	Usage: "Install watch mode as Windows service",
+	Subcommands: []cli.Command{
+		{
+			Name:            "install",
+			Usage:           "Install the service running watch with the arguments, which may include --service-name",
+			ArgsUsage:       "[watch flags] [certificate directory path...]",
+			SkipFlagParsing: true,
+			Action:          runServiceInstall,
+		},
+		{
+			Name:   "remove",
+			Usage:  "Stop and remove the service",
+			Flags:  serviceFlags,
+			Action: runServiceRemove,
+		},
+	},
+}
@@ -0,0 +1,24 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"context"
+	"errors"
+)
+
+var errNoService = errors.New("services are only supported on Windows, use a systemd unit with Type=notify instead")
+
+// runService returns false as there is no service manager to run under.
+func runService(name string, run func(ctx context.Context)) (bool, error) {
+	return false, nil
+}
+
+func installService(name string, exe string, args []string) error {
+	return errNoService
+}
+
+func removeService(name string) error {
+	return errNoService
+}
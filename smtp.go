@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli"
+)
+
+type smtpSettings struct {
+	// addr is the host:port of the mail server.
+	addr     string
+	from     string
+	to       []string
+	username string
+	password string
+}
+
+// loadSMTPSettings returns nil if --smtp-host is not set.
+func loadSMTPSettings(c *cli.Context) (*smtpSettings, error) {
+	host := c.String("smtp-host")
+	if host == "" {
+		return nil, nil
+	}
+
+	if _, _, err := net.SplitHostPort(host); err != nil {
+		host = net.JoinHostPort(host, "25")
+	}
+
+	settings := &smtpSettings{
+		addr:     host,
+		from:     c.String("smtp-from"),
+		to:       splitList(c.String("smtp-to")),
+		username: c.String("smtp-username"),
+	}
+
+	if settings.from == "" || len(settings.to) == 0 {
+		return nil, errors.New("--smtp-from and --smtp-to must be set with --smtp-host")
+	}
+
+	if c.IsSet("smtp-password-file") {
+		content, err := ioutil.ReadFile(c.String("smtp-password-file"))
+		if err != nil {
+			return nil, err
+		}
+
+		settings.password = string(bytes.TrimRight(content, "\r\n"))
+	}
+
+	return settings, nil
+}
+
+// send mails msg as plain text digest. The connection is upgraded with
+// STARTTLS if the server supports it.
+func (s *smtpSettings) send(msg *notification) error {
+	var auth smtp.Auth
+	if s.username != "" {
+		host, _, _ := net.SplitHostPort(s.addr)
+		auth = smtp.PlainAuth("", s.username, s.password, host)
+	}
+
+	subject := fmt.Sprintf("TLS certificates: %d expiring, %d domains dropped", len(msg.Expiring), len(msg.DroppedDomains))
+
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "From: %s\r\n", s.from)
+	fmt.Fprintf(buf, "To: %s\r\n", strings.Join(s.to, ", "))
+	fmt.Fprintf(buf, "Subject: %s\r\n", subject)
+	fmt.Fprintf(buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	buf.WriteString(strings.Replace(msg.Text, "\n", "\r\n", -1) + "\r\n")
+
+	log.WithFields(log.Fields{"server": s.addr, "to": strings.Join(s.to, ",")}).Info("Sending email notification")
+
+	return smtp.SendMail(s.addr, auth, s.from, s.to, buf.Bytes())
+}
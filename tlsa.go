@@ -0,0 +1,211 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/chrisxf/traefik-tls-config-gen/matcher"
+	"github.com/chrisxf/traefik-tls-config-gen/scanner"
+	"github.com/chrisxf/traefik-tls-config-gen/tlsconfig"
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli"
+)
+
+type tlsaRecord struct {
+	Name         string `json:"name"`
+	Usage        int    `json:"usage"`
+	Selector     int    `json:"selector"`
+	MatchingType int    `json:"matchingType"`
+	Data         string `json:"data"`
+	Path         string `json:"path"`
+}
+
+// tlsaData returns the certificate association data of cert, the full
+// certificate with selector 0 or its public key with 1, hashed with SHA-256
+// for matching type 1, SHA-512 for 2 or as is for 0.
+func tlsaData(cert *x509.Certificate, selector int, matchingType int) (string, error) {
+	var data []byte
+
+	switch selector {
+	case 0:
+		data = cert.Raw
+	case 1:
+		data = cert.RawSubjectPublicKeyInfo
+	default:
+		return "", errors.New("unknown TLSA selector " + strconv.Itoa(selector))
+	}
+
+	switch matchingType {
+	case 0:
+	case 1:
+		sum := sha256.Sum256(data)
+		data = sum[:]
+	case 2:
+		sum := sha512.Sum512(data)
+		data = sum[:]
+	default:
+		return "", errors.New("unknown TLSA matching type " + strconv.Itoa(matchingType))
+	}
+
+	return hex.EncodeToString(data), nil
+}
+
+// tlsaRecords returns a record per DNS name of every pair. Usages 0 and 2
+// refer to the topmost CA certificate of the chain, 1 and 3 to the
+// certificate itself.
+func tlsaRecords(pairs []matcher.KeyPair, c *cli.Context) ([]tlsaRecord, error) {
+	usage, selector, matchingType := c.Int("tlsa-usage"), c.Int("tlsa-selector"), c.Int("tlsa-matching-type")
+
+	if usage < 0 || usage > 3 {
+		return nil, errors.New("unknown TLSA usage " + strconv.Itoa(usage))
+	}
+
+	prefix := "_" + strconv.Itoa(c.Int("port")) + "._" + c.String("protocol") + "."
+
+	var records []tlsaRecord
+
+	for _, pair := range pairs {
+		cert := pair.Cert
+
+		if usage == 0 || usage == 2 {
+			if len(pair.Chain) < 2 {
+				scanner.CertLogger(pair.CertPath, pair.Cert).Warn("No CA certificate in chain for TLSA record")
+				continue
+			}
+
+			cert = pair.Chain[len(pair.Chain)-1]
+		}
+
+		data, err := tlsaData(cert, selector, matchingType)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, name := range pair.Cert.DNSNames {
+			if strings.HasPrefix(name, "*.") {
+				log.WithField("domain", name).Debug("Skipping wildcard name for TLSA record")
+				continue
+			}
+
+			records = append(records, tlsaRecord{
+				Name:         prefix + strings.TrimSuffix(strings.ToLower(name), ".") + ".",
+				Usage:        usage,
+				Selector:     selector,
+				MatchingType: matchingType,
+				Data:         data,
+				Path:         pair.CertPath,
+			})
+		}
+	}
+
+	return records, nil
+}
+
+// printTLSA writes the records as zone file lines or JSON.
+func printTLSA(w io.Writer, records []tlsaRecord, asJSON bool, ttl int) error {
+	if asJSON {
+		if records == nil {
+			records = []tlsaRecord{}
+		}
+
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+
+		return encoder.Encode(records)
+	}
+
+	ttlField := ""
+	if ttl > 0 {
+		ttlField = strconv.Itoa(ttl) + " "
+	}
+
+	for _, record := range records {
+		_, err := fmt.Fprintf(w, "%s %sIN TLSA %d %d %d %s\n", record.Name, ttlField, record.Usage, record.Selector, record.MatchingType, record.Data)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func runTLSA(c *cli.Context) {
+	checkArgs(c, false)
+
+	opts, err := loadOptions(c)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ctx, stop := signalContext()
+	defer stop()
+
+	ctx, cancel := runContext(ctx, c)
+	defer cancel()
+
+	result, err := tlsconfig.Scan(ctx, opts)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	records, err := tlsaRecords(result.Pairs, c)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	err = printTLSA(os.Stdout, records, c.Bool("json"), c.Int("ttl"))
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+var tlsaCommand = cli.Command{
+	Name:      "tlsa",
+	Usage:     "Print the DANE TLSA records of the certificates",
+	ArgsUsage: "[certificate directory path...]",
+	Flags: flags(scanFlags, []cli.Flag{
+		cli.IntFlag{
+			Name:  "tlsa-usage",
+			Value: 3,
+			Usage: "Certificate usage: 0 PKIX-TA, 1 PKIX-EE, 2 DANE-TA or 3 DANE-EE; the TA usages refer to the topmost CA certificate of the chain",
+		},
+		cli.IntFlag{
+			Name:  "tlsa-selector",
+			Value: 1,
+			Usage: "Selector: 0 for the full certificate, 1 for the public key",
+		},
+		cli.IntFlag{
+			Name:  "tlsa-matching-type",
+			Value: 1,
+			Usage: "Matching type: 0 for the exact data, 1 for SHA-256, 2 for SHA-512",
+		},
+		cli.IntFlag{
+			Name:  "port",
+			Value: 443,
+			Usage: "Port of the record names",
+		},
+		cli.StringFlag{
+			Name:  "protocol",
+			Value: "tcp",
+			Usage: "Protocol of the record names",
+		},
+		cli.IntFlag{
+			Name:  "ttl",
+			Usage: "TTL of the zone file lines, omitted if 0",
+		},
+		cli.BoolFlag{
+			Name:  "json",
+			Usage: "Print the records as JSON instead of zone file lines",
+		},
+	}),
+	Action: runTLSA,
+}
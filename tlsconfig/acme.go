@@ -0,0 +1,123 @@
+package tlsconfig
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/chrisxf/traefik-tls-config-gen/acme"
+	"github.com/chrisxf/traefik-tls-config-gen/scanner"
+	log "github.com/sirupsen/logrus"
+)
+
+// acmeRetryDelay is the time to wait before requesting a certificate again
+// after it failed, to stay clear of the CA's rate limits.
+const acmeRetryDelay = time.Hour
+
+// ACMESource obtains the certificates for Domains from an ACME CA and
+// renews them when they are due. The certificates are kept in ACME.Dir, which
+// must be the directory of its SourceDir.
+type ACMESource struct {
+	ACME acme.Options
+	// Domains has an entry per certificate, the first domain becoming the
+	// subject.
+	Domains [][]string
+	// RenewDays is the number of days before expiry a certificate is renewed.
+	RenewDays int
+
+	client *acme.Client
+	failed map[string]time.Time
+}
+
+// current returns the certificate and key stored for name if they cover
+// domains and are not due for renewal.
+func (s *ACMESource) current(name string, domains []string) ([]byte, []byte, bool) {
+	certPEM, err := ioutil.ReadFile(filepath.Join(s.ACME.Dir, name+".crt"))
+	if err != nil {
+		return nil, nil, false
+	}
+
+	keyPEM, err := ioutil.ReadFile(filepath.Join(s.ACME.Dir, name+".key"))
+	if err != nil {
+		return nil, nil, false
+	}
+
+	certs := scanner.ParseCertificates(certPEM)
+	if len(certs) == 0 {
+		return nil, nil, false
+	}
+
+	names := append([]string{}, certs[0].DNSNames...)
+	wanted := append([]string{}, domains...)
+	sort.Strings(names)
+	sort.Strings(wanted)
+
+	if strings.Join(names, ",") != strings.Join(wanted, ",") {
+		log.WithFields(log.Fields{"domain": domains[0], "domains": wanted, "certificate": names}).Info("ACME certificate domains changed")
+		return certPEM, keyPEM, false
+	}
+
+	if time.Until(certs[0].NotAfter) < time.Duration(s.RenewDays)*24*time.Hour {
+		log.WithFields(log.Fields{"domain": domains[0], "notAfter": certs[0].NotAfter}).Info("ACME certificate due for renewal")
+		return certPEM, keyPEM, false
+	}
+
+	return certPEM, keyPEM, true
+}
+
+// obtain requests a certificate, creating the client on first use so runs
+// with only valid certificates do not contact the CA.
+func (s *ACMESource) obtain(domains []string) ([]byte, []byte, error) {
+	if s.client == nil {
+		client, err := acme.NewClient(&s.ACME)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		s.client = client
+	}
+
+	log.WithField("domain", domains[0]).Info("Requesting ACME certificate")
+
+	return s.client.Obtain(domains)
+}
+
+// List keeps the certificates that are still valid and requests the others.
+// Failures are logged and retried after acmeRetryDelay, the previous
+// certificate is kept meanwhile.
+func (s *ACMESource) List(ctx context.Context) ([]CertEntry, error) {
+	if s.failed == nil {
+		s.failed = map[string]time.Time{}
+	}
+
+	var entries []CertEntry
+
+	for _, domains := range s.Domains {
+		name := strings.Replace(domains[0], "*", "wildcard", -1)
+		logger := log.WithField("domain", domains[0])
+
+		certPEM, keyPEM, ok := s.current(name, domains)
+
+		if !ok && time.Since(s.failed[name]) > acmeRetryDelay {
+			newCert, newKey, err := s.obtain(domains)
+			if err != nil {
+				logger.WithField("error", err).Error("Could not obtain ACME certificate")
+				s.failed[name] = time.Now()
+			} else {
+				delete(s.failed, name)
+				certPEM, keyPEM = newCert, newKey
+			}
+		}
+
+		if certPEM == nil {
+			continue
+		}
+
+		entries = append(entries, CertEntry{Name: name, Cert: certPEM, Key: keyPEM})
+	}
+
+	return entries, ctx.Err()
+}
@@ -0,0 +1,114 @@
+package tlsconfig
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// acmeCertificate is a certificate of acme.json. Field names differ only in
+// case between Traefik v1 and v2.
+type acmeCertificate struct {
+	Domain struct {
+		Main string
+		SANs []string
+	}
+	Certificate string
+	Key         string
+}
+
+type acmeResolver struct {
+	Certificates []acmeCertificate
+}
+
+// AcmeJSONSource lists the certificates Traefik stored in its acme.json,
+// either the v1 layout with a single account or the v2 one with an entry
+// per certificate resolver.
+type AcmeJSONSource struct {
+	Path string
+}
+
+// isAcmeV1 reports whether the top level keys are those of Traefik v1, which
+// has no certificate resolvers.
+func isAcmeV1(top map[string]json.RawMessage) bool {
+	for name := range top {
+		if strings.EqualFold(name, "Account") || strings.EqualFold(name, "Certificates") {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (s *AcmeJSONSource) List(ctx context.Context) ([]CertEntry, error) {
+	content, err := ioutil.ReadFile(s.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	var top map[string]json.RawMessage
+
+	err = json.Unmarshal(content, &top)
+	if err != nil {
+		return nil, errors.New(s.Path + ": " + err.Error())
+	}
+
+	resolvers := map[string]acmeResolver{}
+
+	if isAcmeV1(top) {
+		var resolver acmeResolver
+
+		err := json.Unmarshal(content, &resolver)
+		if err != nil {
+			return nil, errors.New(s.Path + ": " + err.Error())
+		}
+
+		resolvers["acme"] = resolver
+	} else {
+		for name, raw := range top {
+			var resolver acmeResolver
+
+			err := json.Unmarshal(raw, &resolver)
+			if err != nil {
+				return nil, errors.New(s.Path + ": resolver " + name + ": " + err.Error())
+			}
+
+			resolvers[name] = resolver
+		}
+	}
+
+	replacer := strings.NewReplacer("*", "wildcard", "/", "_")
+
+	var entries []CertEntry
+
+	for name, resolver := range resolvers {
+		for _, cert := range resolver.Certificates {
+			logger := log.WithFields(log.Fields{"resolver": name, "domain": cert.Domain.Main})
+
+			certPEM, err := base64.StdEncoding.DecodeString(cert.Certificate)
+			if err != nil {
+				logger.WithField("error", err).Warn("Could not decode certificate")
+				continue
+			}
+
+			keyPEM, err := base64.StdEncoding.DecodeString(cert.Key)
+			if err != nil {
+				logger.WithField("error", err).Warn("Could not decode private key")
+				continue
+			}
+
+			if len(certPEM) == 0 || len(keyPEM) == 0 {
+				continue
+			}
+
+			entries = append(entries, CertEntry{Name: replacer.Replace(name + "_" + cert.Domain.Main), Cert: certPEM, Key: keyPEM})
+		}
+	}
+
+	return entries, nil
+}
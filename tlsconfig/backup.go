@@ -0,0 +1,92 @@
+package tlsconfig
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const backupTimeFormat = "20060102T150405Z"
+
+// BackupOptions configure the copies of the config kept before it is
+// overwritten. Without Keep the previous config is written to a .bak file,
+// otherwise Keep copies named like "tls.toml.20060102T150405Z.bak" are kept.
+type BackupOptions struct {
+	Keep int
+}
+
+// writeConfig writes content to path after backing up the current file.
+func writeConfig(path string, content []byte, backup *BackupOptions) error {
+	if backup != nil {
+		err := backupConfig(path, backup)
+		if err != nil {
+			return err
+		}
+	}
+
+	return writeFileAtomic(path, content, 0644)
+}
+
+// backupConfig copies the file at path, if it exists, and removes the
+// oldest timestamped copies beyond opts.Keep.
+func backupConfig(path string, opts *BackupOptions) error {
+	previous, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if opts.Keep <= 0 {
+		log.WithField("path", path+".bak").Debug("Backing up config")
+		return writeFileAtomic(path+".bak", previous, 0644)
+	}
+
+	backupPath := path + "." + time.Now().UTC().Format(backupTimeFormat) + ".bak"
+	log.WithField("path", backupPath).Debug("Backing up config")
+
+	err = writeFileAtomic(backupPath, previous, 0644)
+	if err != nil {
+		return err
+	}
+
+	entries, err := ioutil.ReadDir(filepath.Dir(path))
+	if err != nil {
+		return err
+	}
+
+	prefix := filepath.Base(path) + "."
+
+	var backups []string
+	for _, entry := range entries {
+		name := entry.Name()
+		stamp := strings.TrimSuffix(strings.TrimPrefix(name, prefix), ".bak")
+
+		if !entry.IsDir() && strings.HasPrefix(name, prefix) && strings.HasSuffix(name, ".bak") && len(stamp) == len(backupTimeFormat) {
+			backups = append(backups, name)
+		}
+	}
+
+	sort.Strings(backups)
+
+	for len(backups) > opts.Keep {
+		oldest := filepath.Join(filepath.Dir(path), backups[0])
+		backups = backups[1:]
+
+		log.WithField("path", oldest).Debug("Removing old config backup")
+
+		err = os.Remove(oldest)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,181 @@
+package tlsconfig
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/chrisxf/traefik-tls-config-gen/matcher"
+	"github.com/chrisxf/traefik-tls-config-gen/scanner"
+	log "github.com/sirupsen/logrus"
+)
+
+// Modes of placing the files in the copy directory.
+const (
+	CopyModeCopy     = "copy"
+	CopyModeHardlink = "hardlink"
+	// CopyModeBundle writes the certificates and the key into one .pem
+	// file, as HAProxy loads them.
+	CopyModeBundle = "bundle"
+)
+
+// linkFile hardlinks path to target unless it already is one.
+func linkFile(target string, path string) error {
+	targetInfo, err := os.Stat(target)
+	if err != nil {
+		return err
+	}
+
+	if info, err := os.Stat(path); err == nil && os.SameFile(info, targetInfo) {
+		return nil
+	}
+
+	tmp := filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+".link")
+	os.Remove(tmp)
+
+	err = os.Link(target, tmp)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
+
+// pairCopy is a pair placed in the copy directory, at CertPath and KeyPath.
+type pairCopy struct {
+	Source   matcher.KeyPair
+	CertPath string
+	KeyPath  string
+}
+
+// planCopies names the cert and key of every pair in dir after the common
+// name of the certificate and points the pairs at them, without writing
+// anything. Combined files are split, unless bundling.
+func planCopies(pairs []matcher.KeyPair, dir string, mode string) ([]matcher.KeyPair, []pairCopy, error) {
+	switch mode {
+	case CopyModeCopy, CopyModeHardlink, CopyModeBundle, "":
+	default:
+		return nil, nil, errors.New("unknown copy mode " + mode)
+	}
+
+	var copies []pairCopy
+
+	keep := map[string]bool{}
+
+	for i, pair := range pairs {
+		name := pairFileName(pair)
+		for n := 2; keep[name+".crt"] || keep[name+".pem"]; n++ {
+			name = pairFileName(pair) + "-" + strconv.Itoa(n)
+		}
+
+		if mode == CopyModeBundle {
+			bundlePath := filepath.Join(dir, name+".pem")
+			keep[name+".pem"] = true
+
+			copies = append(copies, pairCopy{Source: pair, CertPath: bundlePath, KeyPath: bundlePath})
+			pairs[i].CertPath, pairs[i].KeyPath = bundlePath, bundlePath
+			continue
+		}
+
+		certPath, keyPath := filepath.Join(dir, name+".crt"), filepath.Join(dir, name+".key")
+		keep[name+".crt"], keep[name+".key"] = true, true
+
+		copies = append(copies, pairCopy{Source: pair, CertPath: certPath, KeyPath: keyPath})
+		pairs[i].CertPath, pairs[i].KeyPath = certPath, keyPath
+	}
+
+	return pairs, copies, nil
+}
+
+// copyPairs writes the copies planned by planCopies to dir, hardlinked,
+// bundled or copied by mode. Combined files are always copied, unless
+// bundling. It returns the cleanup removing the other .crt, .key and .pem
+// files from dir.
+func copyPairs(copies []pairCopy, dir string, mode string) (cleanup, error) {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return cleanup{}, err
+	}
+
+	keep := map[string]bool{}
+
+	for _, c := range copies {
+		pair := c.Source
+		keep[filepath.Base(c.CertPath)], keep[filepath.Base(c.KeyPath)] = true, true
+
+		if mode == CopyModeBundle {
+			err = bundlePair(pair, c.CertPath)
+			if err != nil {
+				return cleanup{}, err
+			}
+
+			scanner.CertLogger(pair.CertPath, pair.Cert).WithField("copy", c.CertPath).Debug("Bundled pair")
+			continue
+		}
+
+		if mode == CopyModeHardlink && pair.CertPath != pair.KeyPath {
+			err = linkFile(pair.CertPath, c.CertPath)
+			if err == nil {
+				err = linkFile(pair.KeyPath, c.KeyPath)
+			}
+		} else {
+			err = copyPair(pair, c.CertPath, c.KeyPath)
+		}
+
+		if err != nil {
+			return cleanup{}, err
+		}
+
+		scanner.CertLogger(pair.CertPath, pair.Cert).WithField("copy", c.CertPath).Debug("Copied pair")
+	}
+
+	log.WithFields(log.Fields{"dir": dir, "pairs": len(copies)}).Info("Copied pairs")
+
+	return cleanup{Dir: dir, Keep: keep, Exts: []string{".crt", ".key", ".pem"}}, nil
+}
+
+// bundlePair writes the certificates of the cert file of pair followed by its
+// private key to path.
+func bundlePair(pair matcher.KeyPair, path string) error {
+	certContent, err := ioutil.ReadFile(pair.CertPath)
+	if err != nil {
+		return err
+	}
+
+	keyContent, err := ioutil.ReadFile(pair.KeyPath)
+	if err != nil {
+		return err
+	}
+
+	certs, _ := scanner.SplitPEM(certContent)
+	_, keys := scanner.SplitPEM(keyContent)
+
+	return writeFileIfChanged(path, append(certs, keys...), 0600)
+}
+
+// copyPair writes the certificates of the cert file of pair to certPath and
+// the private key of its key file to keyPath.
+func copyPair(pair matcher.KeyPair, certPath string, keyPath string) error {
+	certContent, err := ioutil.ReadFile(pair.CertPath)
+	if err != nil {
+		return err
+	}
+
+	keyContent, err := ioutil.ReadFile(pair.KeyPath)
+	if err != nil {
+		return err
+	}
+
+	if pair.CertPath == pair.KeyPath {
+		certContent, keyContent = scanner.SplitPEM(certContent)
+	}
+
+	err = writeFileIfChanged(certPath, certContent, 0644)
+	if err != nil {
+		return err
+	}
+
+	return writeFileIfChanged(keyPath, keyContent, 0600)
+}
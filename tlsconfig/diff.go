@@ -0,0 +1,221 @@
+package tlsconfig
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/chrisxf/traefik-tls-config-gen/matcher"
+	"github.com/chrisxf/traefik-tls-config-gen/render"
+	"github.com/chrisxf/traefik-tls-config-gen/scanner"
+	log "github.com/sirupsen/logrus"
+)
+
+// diffContext is the number of unchanged lines shown around each change.
+const diffContext = 3
+
+type diffLine struct {
+	op   byte
+	text string
+}
+
+// Diff returns a unified diff between a and b, or an empty string if they
+// are equal. It is meant for config files of moderate size.
+func Diff(nameA, nameB string, a, b []byte) string {
+	if bytes.Equal(a, b) {
+		return ""
+	}
+
+	linesA := splitLines(a)
+	linesB := splitLines(b)
+
+	// lcs[i][j] is the length of the longest common subsequence of
+	// linesA[i:] and linesB[j:].
+	lcs := make([][]int, len(linesA)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(linesB)+1)
+	}
+
+	for i := len(linesA) - 1; i >= 0; i-- {
+		for j := len(linesB) - 1; j >= 0; j-- {
+			if linesA[i] == linesB[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var lines []diffLine
+
+	i, j := 0, 0
+	for i < len(linesA) || j < len(linesB) {
+		switch {
+		case i < len(linesA) && j < len(linesB) && linesA[i] == linesB[j]:
+			lines = append(lines, diffLine{' ', linesA[i]})
+			i++
+			j++
+		case i < len(linesA) && (j == len(linesB) || lcs[i+1][j] >= lcs[i][j+1]):
+			lines = append(lines, diffLine{'-', linesA[i]})
+			i++
+		default:
+			lines = append(lines, diffLine{'+', linesB[j]})
+			j++
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "--- %s\n+++ %s\n", nameA, nameB)
+
+	// Group changes into hunks, merging those whose context overlaps.
+	for start := 0; start < len(lines); {
+		if lines[start].op == ' ' {
+			start++
+			continue
+		}
+
+		first := start - diffContext
+		if first < 0 {
+			first = 0
+		}
+
+		end := start
+		for k := start; k < len(lines) && k <= end+2*diffContext; k++ {
+			if lines[k].op != ' ' {
+				end = k
+			}
+		}
+
+		last := end + diffContext + 1
+		if last > len(lines) {
+			last = len(lines)
+		}
+
+		lineA, lineB := 1, 1
+		for _, line := range lines[:first] {
+			if line.op != '+' {
+				lineA++
+			}
+			if line.op != '-' {
+				lineB++
+			}
+		}
+
+		countA, countB := 0, 0
+		for _, line := range lines[first:last] {
+			if line.op != '+' {
+				countA++
+			}
+			if line.op != '-' {
+				countB++
+			}
+		}
+
+		// Empty ranges start at the line before, like in GNU diff.
+		if countA == 0 {
+			lineA--
+		}
+		if countB == 0 {
+			lineB--
+		}
+
+		fmt.Fprintf(buf, "@@ -%d,%d +%d,%d @@\n", lineA, countA, lineB, countB)
+
+		for _, line := range lines[first:last] {
+			buf.WriteByte(line.op)
+			buf.WriteString(line.text)
+			buf.WriteByte('\n')
+		}
+
+		start = last
+	}
+
+	return buf.String()
+}
+
+func splitLines(content []byte) []string {
+	if len(content) == 0 {
+		return nil
+	}
+
+	return strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
+}
+
+var certFilePattern = regexp.MustCompile(`(?m)^\s*certFile = ("(?:[^"\\]|\\.)*")`)
+
+// certFiles returns the cert files referenced in a generated config.
+func certFiles(content []byte) map[string]bool {
+	files := map[string]bool{}
+	for _, match := range certFilePattern.FindAllSubmatch(content, -1) {
+		// TOML basic strings use the same escapes as Go string literals.
+		if file, err := strconv.Unquote(string(match[1])); err == nil {
+			files[file] = true
+		}
+	}
+
+	return files
+}
+
+var annotationPattern = regexp.MustCompile(`(?m)^# CN=(.*) SANs=(\S*) NotAfter=\S+$`)
+
+// droppedDomains returns the domains annotated in the previous config that no
+// certificate in pairs covers anymore. Configs written without annotations
+// yield none.
+func droppedDomains(previous []byte, pairs []matcher.KeyPair) []string {
+	current := map[string]bool{}
+	for _, pair := range pairs {
+		for _, domain := range matcher.Domains(pair.Cert) {
+			current[strings.ToLower(domain)] = true
+		}
+	}
+
+	seen := map[string]bool{}
+
+	var dropped []string
+
+	for _, match := range annotationPattern.FindAllSubmatch(previous, -1) {
+		domains := strings.Split(string(match[2]), ",")
+		if len(match[2]) == 0 {
+			domains = []string{string(match[1])}
+		}
+
+		for _, domain := range domains {
+			domain = strings.ToLower(domain)
+			if domain == "" || current[domain] || seen[domain] {
+				continue
+			}
+
+			seen[domain] = true
+			dropped = append(dropped, domain)
+		}
+	}
+
+	sort.Strings(dropped)
+
+	return dropped
+}
+
+// logCertChanges logs the certificates added to or removed from the config,
+// with their domains where they are known.
+func logCertChanges(previous, content []byte, pairs []matcher.KeyPair, opts *render.Options) {
+	before := certFiles(previous)
+	after := certFiles(content)
+
+	for _, pair := range pairs {
+		certPath := opts.ConfigPath(pair.CertPath)
+		if after[certPath] && !before[certPath] {
+			scanner.CertLogger(certPath, pair.Cert).Info("Certificate added")
+		}
+	}
+
+	for certPath := range before {
+		if !after[certPath] {
+			log.WithField("path", certPath).Info("Certificate removed")
+		}
+	}
+}
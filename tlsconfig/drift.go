@@ -0,0 +1,229 @@
+package tlsconfig
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chrisxf/traefik-tls-config-gen/matcher"
+	log "github.com/sirupsen/logrus"
+)
+
+// APIOptions configure the access to the Traefik API.
+type APIOptions struct {
+	// URL is the base URL of the API, e.g. http://traefik:8080.
+	URL string
+	// Username and Password enable basic auth, Token bearer auth.
+	Username string
+	Password string
+	Token    string
+}
+
+// Drift are the differences between the generated config and the routers
+// Traefik has loaded.
+type Drift struct {
+	// Uncovered are the hosts of TLS routers no pair covers, Traefik serves
+	// its default certificate for them.
+	Uncovered []string
+	// Unused are the certificate files of the pairs no TLS router uses.
+	Unused []string
+	// RouterErrors are the TLS routers Traefik reports errors for.
+	RouterErrors []string
+}
+
+// Found reports whether there is any drift.
+func (d *Drift) Found() bool {
+	return len(d.Uncovered) > 0 || len(d.Unused) > 0 || len(d.RouterErrors) > 0
+}
+
+type router struct {
+	Name   string   `json:"name"`
+	Rule   string   `json:"rule"`
+	Status string   `json:"status"`
+	Errors []string `json:"error"`
+	TLS    *struct {
+		Domains []struct {
+			Main string   `json:"main"`
+			SANs []string `json:"sans"`
+		} `json:"domains"`
+	} `json:"tls"`
+}
+
+var (
+	hostMatcher  = regexp.MustCompile(`Host(?:SNI)?\(([^)]*)\)`)
+	quotedString = regexp.MustCompile("[`\"']([^`\"']+)[`\"']")
+)
+
+// hosts returns the host names of the Host and HostSNI matchers of the rule
+// and the TLS domains of r.
+func (r *router) hosts() []string {
+	var hosts []string
+
+	for _, match := range hostMatcher.FindAllStringSubmatch(r.Rule, -1) {
+		for _, quoted := range quotedString.FindAllStringSubmatch(match[1], -1) {
+			if quoted[1] != "*" {
+				hosts = append(hosts, strings.ToLower(quoted[1]))
+			}
+		}
+	}
+
+	for _, domain := range r.TLS.Domains {
+		hosts = append(hosts, strings.ToLower(domain.Main))
+		for _, san := range domain.SANs {
+			hosts = append(hosts, strings.ToLower(san))
+		}
+	}
+
+	return hosts
+}
+
+// getRouters returns all routers of the protocol, following the pagination
+// of the API. A protocol the API does not know yields no routers.
+func getRouters(ctx context.Context, opts *APIOptions, protocol string) ([]router, error) {
+	client := &http.Client{Timeout: 30 * time.Second}
+
+	var routers []router
+
+	for page := "1"; page != ""; {
+		url := strings.TrimSuffix(opts.URL, "/") + "/api/" + protocol + "/routers?per_page=100&page=" + page
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		if opts.Username != "" {
+			req.SetBasicAuth(opts.Username, opts.Password)
+		}
+
+		if opts.Token != "" {
+			req.Header.Set("Authorization", "Bearer "+opts.Token)
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+
+		content, err := ioutil.ReadAll(io.LimitReader(resp.Body, 64<<20))
+		resp.Body.Close()
+
+		if err != nil {
+			return nil, err
+		}
+
+		if resp.StatusCode == http.StatusNotFound {
+			return routers, nil
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			return nil, errors.New("traefik API returned " + resp.Status + " for " + url)
+		}
+
+		var batch []router
+
+		err = json.Unmarshal(content, &batch)
+		if err != nil {
+			return nil, errors.New("traefik API: " + err.Error())
+		}
+
+		routers = append(routers, batch...)
+
+		page = resp.Header.Get("X-Next-Page")
+		if next, err := strconv.Atoi(page); err != nil || next <= 1 {
+			page = ""
+		}
+	}
+
+	return routers, nil
+}
+
+// CheckDrift scans opts.Dirs like Generate in check mode and compares the
+// pairs with the TLS routers Traefik reports. Result.Diff holds the
+// difference between opts.Out and the generated config, if Out is set.
+func CheckDrift(ctx context.Context, opts Options, api *APIOptions) (Result, Drift, error) {
+	var drift Drift
+
+	var result Result
+	var err error
+
+	if opts.Out != "" {
+		opts.Check = true
+		result, err = Generate(ctx, opts)
+	} else {
+		result, err = Scan(ctx, opts)
+	}
+
+	if err != nil {
+		return result, drift, err
+	}
+
+	var routers []router
+
+	for _, protocol := range []string{"http", "tcp"} {
+		batch, err := getRouters(ctx, api, protocol)
+		if err != nil {
+			return result, drift, err
+		}
+
+		routers = append(routers, batch...)
+	}
+
+	hosts := map[string]bool{}
+
+	for i := range routers {
+		r := &routers[i]
+		if r.TLS == nil {
+			continue
+		}
+
+		if len(r.Errors) > 0 {
+			log.WithFields(log.Fields{"router": r.Name, "error": strings.Join(r.Errors, "; ")}).Warn("Router has errors")
+			drift.RouterErrors = append(drift.RouterErrors, r.Name)
+		}
+
+		for _, host := range r.hosts() {
+			hosts[host] = true
+		}
+	}
+
+	var sorted []string
+	for host := range hosts {
+		sorted = append(sorted, host)
+	}
+
+	sort.Strings(sorted)
+
+	for _, host := range sorted {
+		if !coveredBy(result.Pairs, host) {
+			log.WithField("host", host).Warn("No certificate for host of TLS router")
+			drift.Uncovered = append(drift.Uncovered, host)
+		}
+	}
+
+	for _, pair := range result.Pairs {
+		used := false
+
+		for _, host := range sorted {
+			if coveredBy([]matcher.KeyPair{pair}, host) {
+				used = true
+				break
+			}
+		}
+
+		if !used {
+			log.WithField("path", pair.CertPath).Info("Certificate not used by any TLS router")
+			drift.Unused = append(drift.Unused, pair.CertPath)
+		}
+	}
+
+	return result, drift, nil
+}
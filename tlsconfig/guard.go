@@ -0,0 +1,76 @@
+package tlsconfig
+
+import (
+	"bytes"
+	"encoding/json"
+	"regexp"
+	"strconv"
+
+	"github.com/chrisxf/traefik-tls-config-gen/render"
+)
+
+var certEntryPattern = regexp.MustCompile(`(?m)^\[\[tls(\.certificates)?\]\]`)
+
+// ShrinkError is returned by Generate and UpdateLineage instead of writing a
+// config with fewer pairs than Options.MinPairs, or with more than
+// MaxRemovedPercent of the pairs of the previous config removed.
+type ShrinkError struct {
+	Previous int
+	Pairs    int
+}
+
+func (e *ShrinkError) Error() string {
+	return "refusing to write config with " + strconv.Itoa(e.Pairs) + " pairs, previously " + strconv.Itoa(e.Previous)
+}
+
+// previousPairs returns the number of certificates in a previous config. In
+// the generated block they are counted by their entries or annotations, so
+// hand-written entries and the default certificate are left out. Configs
+// without block, as written in the JSON formats, are counted by their
+// tls.certificates.
+func previousPairs(previous []byte) int {
+	start := bytes.Index(previous, []byte(render.ConfigHeader))
+	if start < 0 {
+		config := &dynamicConfig{}
+		if json.Unmarshal(previous, config) != nil {
+			return 0
+		}
+
+		return len(config.TLS.Certificates)
+	}
+
+	block := previous[start:]
+	if end := bytes.Index(block, []byte(render.ConfigFooter)); end >= 0 {
+		block = block[:end]
+	}
+
+	count := len(certEntryPattern.FindAllIndex(block, -1))
+
+	// Formats like nginx annotate a pair more than once.
+	annotations := map[string]bool{}
+	for _, annotation := range annotationPattern.FindAll(block, -1) {
+		annotations[string(annotation)] = true
+	}
+
+	if len(annotations) > count {
+		count = len(annotations)
+	}
+
+	return count
+}
+
+// checkShrink returns a ShrinkError if pairs falls below the limits of opts
+// compared to the previous config.
+func checkShrink(previous []byte, pairs int, opts *Options) error {
+	count := previousPairs(previous)
+
+	if pairs < opts.MinPairs {
+		return &ShrinkError{Previous: count, Pairs: pairs}
+	}
+
+	if opts.MaxRemovedPercent > 0 && count > 0 && (count-pairs)*100 > opts.MaxRemovedPercent*count {
+		return &ShrinkError{Previous: count, Pairs: pairs}
+	}
+
+	return nil
+}
@@ -0,0 +1,103 @@
+package tlsconfig
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/pem"
+	"io/ioutil"
+	"regexp"
+	"strings"
+
+	"github.com/chrisxf/traefik-tls-config-gen/kube"
+	"github.com/chrisxf/traefik-tls-config-gen/matcher"
+	"github.com/chrisxf/traefik-tls-config-gen/scanner"
+)
+
+// KubeOptions select the cluster and namespace the pairs are applied to as
+// TLS secrets.
+type KubeOptions struct {
+	Client *kube.Client
+	// Namespace defaults to the namespace of the client config.
+	Namespace string
+}
+
+var invalidNameChars = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// secretName derives a stable secret name from the first domain of the
+// certificate, so renewals update the same secret.
+func secretName(pair *matcher.KeyPair) string {
+	domain := strings.ToLower(matcher.Domains(pair.Cert)[0])
+	domain = strings.Replace(domain, "*", "wildcard", -1)
+
+	name := strings.Trim(invalidNameChars.ReplaceAllString(domain, "-"), "-")
+	if name == "" {
+		sum := sha256.Sum256([]byte(pair.CertPath))
+		name = hex.EncodeToString(sum[:4])
+	}
+
+	if len(name) > 240 {
+		name = name[:240]
+	}
+
+	return name + "-tls"
+}
+
+// kubeSecrets returns a TLS secret for every pair. Pairs sharing a secret
+// name get a suffix derived from their cert path.
+func kubeSecrets(pairs []matcher.KeyPair, passphrases *scanner.Passphrases) ([]kube.Secret, error) {
+	var secrets []kube.Secret
+
+	names := map[string]bool{}
+
+	for i := range pairs {
+		pair := &pairs[i]
+
+		name := secretName(pair)
+		if names[name] {
+			sum := sha256.Sum256([]byte(pair.CertPath))
+			name = strings.TrimSuffix(name, "-tls") + "-" + hex.EncodeToString(sum[:4]) + "-tls"
+		}
+
+		names[name] = true
+
+		chain := pair.Chain
+		if len(chain) == 0 {
+			chain = append(chain, pair.Cert)
+		}
+
+		var certPEM []byte
+		for _, cert := range chain {
+			certPEM = append(certPEM, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})...)
+		}
+
+		content, err := ioutil.ReadFile(pair.KeyPath)
+		if err != nil {
+			return nil, err
+		}
+
+		keyPEM, err := scanner.PrivateKeyPEM(content, passphrases.Lookup(pair.KeyPath))
+		if err != nil {
+			return nil, err
+		}
+
+		secrets = append(secrets, kube.NewTLSSecret(name, certPEM, keyPEM, pair.CertPath))
+	}
+
+	return secrets, nil
+}
+
+// applyKube creates, updates and prunes the TLS secrets of the pairs.
+func applyKube(ctx context.Context, opts *KubeOptions, pairs []matcher.KeyPair, passphrases *scanner.Passphrases) error {
+	secrets, err := kubeSecrets(pairs, passphrases)
+	if err != nil {
+		return err
+	}
+
+	namespace := opts.Namespace
+	if namespace == "" {
+		namespace = opts.Client.Namespace()
+	}
+
+	return opts.Client.ApplySecrets(ctx, namespace, secrets)
+}
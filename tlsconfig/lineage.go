@@ -0,0 +1,237 @@
+package tlsconfig
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chrisxf/traefik-tls-config-gen/render"
+	log "github.com/sirupsen/logrus"
+)
+
+// splitEntries returns the entries of an autogenerated block, in order.
+// Entries are separated by blank lines.
+func splitEntries(block []byte) []string {
+	block = bytes.TrimPrefix(block, []byte(render.ConfigHeader))
+	block = bytes.TrimSuffix(block, []byte(render.ConfigFooter))
+
+	var entries []string
+	for _, entry := range strings.Split(string(block), "\n\n") {
+		if entry = strings.Trim(entry, "\n"); entry != "" {
+			entries = append(entries, entry)
+		}
+	}
+
+	return entries
+}
+
+func joinEntries(entries []string) []byte {
+	buf := &bytes.Buffer{}
+	buf.WriteString(render.ConfigHeader + "\n\n")
+
+	for _, entry := range entries {
+		buf.WriteString(entry + "\n\n")
+	}
+
+	buf.WriteString(render.ConfigFooter)
+
+	return buf.Bytes()
+}
+
+func isCertEntry(entry string) bool {
+	return strings.Contains(entry, "[[tls]]") || strings.Contains(entry, "[[tls.certificates]]")
+}
+
+// entryAffected reports whether the certificate of entry is stored below dir
+// or is one of certFiles, or its annotation lists one of domains.
+func entryAffected(entry string, dir string, certFiles map[string]bool, domains map[string]bool) bool {
+	if match := certFilePattern.FindStringSubmatch(entry); match != nil {
+		if file, err := strconv.Unquote(match[1]); err == nil && (certFiles[file] || strings.HasPrefix(file, dir+string(filepath.Separator))) {
+			return true
+		}
+	}
+
+	match := annotationPattern.FindStringSubmatch(entry)
+	if match == nil {
+		return false
+	}
+
+	for _, domain := range append(strings.Split(match[2], ","), match[1]) {
+		if domains[strings.ToLower(domain)] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// UpdateLineage scans only lineage, a directory renewed by certbot, and
+// replaces the entries of opts.Out whose certificate is stored there or
+// covers one of domains with the pairs found. All other entries are kept as
+// they are, so the rest of the certificates is not scanned again. The copies
+// of the pairs in opts.CopyTo are written, but unlike Generate no other file
+// is removed from it. Only the traefik output format is supported, the other
+// outputs, Stamp and NoHeader are rejected.
+func UpdateLineage(ctx context.Context, opts Options, lineage string, domains []string) (Result, error) {
+	start := time.Now()
+
+	if opts.Out == "" {
+		return Result{}, errors.New("output file must be set")
+	}
+
+	if opts.Format != "" && opts.Format != "traefik" {
+		return Result{}, errors.New("only the traefik output format can be updated incrementally")
+	}
+
+	if opts.OutDir != "" || len(opts.Outputs) > 0 || opts.Push != nil || opts.KV != nil || opts.Kube != nil {
+		return Result{}, errors.New("only the output file can be updated incrementally")
+	}
+
+	if opts.Stamp != nil || opts.NoHeader {
+		return Result{}, errors.New("configs with stamp or without header cannot be updated incrementally")
+	}
+
+	// The same lock as Generate, a deploy hook may run while watching.
+	release, err := lockOutputs(ctx, &opts)
+	if err != nil {
+		return Result{}, err
+	}
+
+	defer release()
+
+	previous, err := ioutil.ReadFile(opts.Out)
+	if err != nil && !os.IsNotExist(err) {
+		return Result{}, err
+	}
+
+	setDirPrefixes(&opts)
+
+	lineage = filepath.Clean(lineage)
+	opts.Dirs = []Dir{{Path: lineage}}
+	opts.Sources = nil
+
+	result, err := scan(ctx, opts, true)
+	if err != nil {
+		return result, err
+	}
+
+	// Default certificate and client CAs stay as they are in the previous
+	// config, only the certificate entries are rendered.
+	renderOpts := opts.Render
+	renderOpts.DefaultCert, renderOpts.DefaultDomain, renderOpts.CAFiles = "", "", nil
+
+	var added []string
+	for _, entry := range splitEntries(render.Traefik(result.Pairs, &renderOpts)) {
+		if isCertEntry(entry) {
+			added = append(added, entry)
+		}
+	}
+
+	var oldBlock []byte
+	if i := bytes.Index(previous, []byte(render.ConfigHeader)); i >= 0 {
+		oldBlock = previous[i:]
+		if end := bytes.Index(oldBlock, []byte(render.ConfigFooter)); end >= 0 {
+			oldBlock = oldBlock[:end+len(render.ConfigFooter)]
+		}
+	}
+
+	domainSet := map[string]bool{}
+	for _, domain := range domains {
+		domainSet[strings.ToLower(domain)] = true
+	}
+
+	configDir := opts.Render.ConfigPath(lineage)
+
+	// With opts.CopyTo, the entries refer to the copies instead.
+	certFiles := map[string]bool{}
+	for _, pair := range result.Pairs {
+		certFiles[opts.Render.ConfigPath(pair.CertPath)] = true
+	}
+
+	var entries []string
+
+	inserted := false
+	lastCert := -1
+
+	for _, entry := range splitEntries(oldBlock) {
+		if !isCertEntry(entry) {
+			entries = append(entries, entry)
+			continue
+		}
+
+		if !entryAffected(entry, configDir, certFiles, domainSet) {
+			entries = append(entries, entry)
+			lastCert = len(entries) - 1
+			continue
+		}
+
+		log.WithField("entry", strings.SplitN(entry, "\n", 2)[0]).Debug("Replacing entry")
+
+		if !inserted {
+			entries = append(entries, added...)
+			lastCert = len(entries) - 1
+			inserted = true
+		}
+	}
+
+	if !inserted {
+		entries = append(entries[:lastCert+1], append(added, entries[lastCert+1:]...)...)
+	}
+
+	content := render.Merge(previous, joinEntries(entries))
+
+	result.Changed = previous == nil || !bytes.Equal(previous, content)
+
+	if result.Changed && !opts.NoValidate {
+		err = validateConfig(opts.Out, "traefik", content, &result, &opts.Render)
+		if err != nil {
+			return result, err
+		}
+	}
+
+	pairs := 0
+	for _, entry := range entries {
+		if isCertEntry(entry) {
+			pairs++
+		}
+	}
+
+	// An empty lineage, e.g. of an unmounted share, must not drop the
+	// entries of the previous config either.
+	err = checkShrink(previous, pairs, &opts)
+	if err != nil {
+		return result, err
+	}
+
+	// The cleanups are not run, the files of the other pairs are still in
+	// use.
+	err = writePairFiles(ctx, &opts, &result)
+	if err != nil {
+		return result, err
+	}
+
+	if !result.Changed {
+		log.WithField("path", opts.Out).Info("Config unchanged, skipping write")
+	} else {
+		if previous != nil {
+			result.Diff = Diff(opts.Out, opts.Out+" (generated)", previous, content)
+		}
+
+		log.WithFields(log.Fields{"path": opts.Out, "lineage": lineage, "pairs": len(result.Pairs)}).Info("Updating config")
+
+		err = writeConfig(opts.Out, content, opts.Backup)
+		if err != nil {
+			return result, err
+		}
+	}
+
+	result.Duration = time.Since(start)
+
+	return result, nil
+}
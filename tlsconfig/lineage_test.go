@@ -0,0 +1,59 @@
+package tlsconfig
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/chrisxf/traefik-tls-config-gen/render"
+)
+
+func TestUpdateLineageChecksShrink(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.Join(t.TempDir(), "out.toml")
+
+	domains := []string{"a.example.com", "b.example.com"}
+	for _, domain := range domains {
+		cert, keyDER := newPair(t, domain, time.Now().Add(time.Hour))
+
+		writePEM(t, filepath.Join(dir, domain, "cert.pem"), "CERTIFICATE", cert)
+		writePEM(t, filepath.Join(dir, domain, "privkey.pem"), "EC PRIVATE KEY", keyDER)
+	}
+
+	opts := Options{
+		Dirs:     []Dir{{Path: dir}},
+		Out:      out,
+		Render:   render.Options{TraefikVersion: 2},
+		MinPairs: 2,
+	}
+
+	_, err := Generate(context.Background(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	previous, err := ioutil.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = UpdateLineage(context.Background(), opts, t.TempDir(), domains)
+
+	var shrinkErr *ShrinkError
+	if !errors.As(err, &shrinkErr) {
+		t.Fatalf("got error %v, want ShrinkError", err)
+	}
+
+	content, err := ioutil.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(content, previous) {
+		t.Errorf("config changed to:\n%s", content)
+	}
+}
@@ -0,0 +1,94 @@
+package tlsconfig
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const lockRetryInterval = 200 * time.Millisecond
+
+// lockPath returns opts.LockFile or the lock file next to the config.
+func lockPath(opts *Options) string {
+	if opts.LockFile != "" {
+		return opts.LockFile
+	}
+
+	if opts.Out != "" {
+		return opts.Out + ".lock"
+	}
+
+	if opts.OutDir != "" {
+		return filepath.Join(opts.OutDir, ".lock")
+	}
+
+	return ""
+}
+
+// lockOutputs takes the lock of opts, unless it is disabled or nothing is
+// written, and returns the function releasing it.
+func lockOutputs(ctx context.Context, opts *Options) (func(), error) {
+	path := lockPath(opts)
+	if path == "" || opts.NoLock || opts.Check || opts.DryRun {
+		return func() {}, nil
+	}
+
+	release, err := acquireLock(ctx, path)
+	if err != nil {
+		return nil, errors.New("lock " + path + ": " + err.Error())
+	}
+
+	return release, nil
+}
+
+// acquireLock takes the advisory lock on path, waiting for other runs until
+// ctx is done, and returns the function releasing it. The directory of path
+// is created, it may be the output directory not written yet.
+func acquireLock(ctx context.Context, path string) (func(), error) {
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	logged := false
+
+	for {
+		locked, err := tryLock(file)
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+
+		if locked {
+			break
+		}
+
+		if !logged {
+			log.WithField("path", path).Info("Waiting for lock held by another run")
+			logged = true
+		}
+
+		select {
+		case <-ctx.Done():
+			file.Close()
+			return nil, ctx.Err()
+		case <-time.After(lockRetryInterval):
+		}
+	}
+
+	log.WithField("path", path).Debug("Acquired lock")
+
+	return func() {
+		unlock(file)
+		file.Close()
+	}, nil
+}
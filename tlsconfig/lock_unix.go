@@ -0,0 +1,24 @@
+//go:build !windows
+// +build !windows
+
+package tlsconfig
+
+import (
+	"os"
+	"syscall"
+)
+
+// tryLock takes an exclusive flock on file without blocking and reports
+// whether it succeeded.
+func tryLock(file *os.File) (bool, error) {
+	err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if err == syscall.EWOULDBLOCK {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+func unlock(file *os.File) error {
+	return syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+}
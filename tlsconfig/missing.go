@@ -0,0 +1,176 @@
+package tlsconfig
+
+import (
+	"context"
+	"crypto/x509"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/chrisxf/traefik-tls-config-gen/certgen"
+	"github.com/chrisxf/traefik-tls-config-gen/matcher"
+	"github.com/chrisxf/traefik-tls-config-gen/scanner"
+	log "github.com/sirupsen/logrus"
+)
+
+// generatedRenewBefore is the remaining validity below which a generated
+// certificate is issued again.
+const generatedRenewBefore = 7 * 24 * time.Hour
+
+// GenerateOptions configure the certificates generated for domains not
+// covered by any pair.
+type GenerateOptions struct {
+	Domains []string
+	// Dir receives the generated pairs. Files of domains no longer missing
+	// are removed from it once the config is written.
+	Dir string
+	// CA signs the certificates, they are self-signed if nil.
+	CA       *certgen.CA
+	Validity time.Duration
+}
+
+// covers reports whether cert is valid for domain. Wildcard domains must be
+// listed in the certificate as is.
+func covers(cert *x509.Certificate, domain string) bool {
+	for _, name := range matcher.Domains(cert) {
+		if strings.EqualFold(name, domain) {
+			return true
+		}
+	}
+
+	return !strings.HasPrefix(domain, "*.") && cert.VerifyHostname(domain) == nil
+}
+
+// missingDomains logs and returns the domains no pair covers.
+func missingDomains(pairs []matcher.KeyPair, domains []string) []string {
+	var missing []string
+
+	for _, domain := range domains {
+		if !coveredBy(pairs, domain) {
+			log.WithField("domain", domain).Warn("No certificate covers required domain")
+			missing = append(missing, domain)
+		}
+	}
+
+	return missing
+}
+
+// coveredBy reports whether a certificate of pairs is valid for domain.
+func coveredBy(pairs []matcher.KeyPair, domain string) bool {
+	for _, pair := range pairs {
+		if covers(pair.Cert, domain) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// generatedName returns the file name without extension for domain.
+func generatedName(domain string) string {
+	return strings.NewReplacer("*", "wildcard", ":", "_").Replace(strings.ToLower(domain))
+}
+
+// reusable reports whether the generated certificate in content is still
+// valid for domain and signed as configured.
+func reusable(content []byte, domain string, opts *GenerateOptions) bool {
+	certs := scanner.ParseCertificates(content)
+	if len(certs) == 0 {
+		return false
+	}
+
+	cert := certs[0]
+
+	if time.Until(cert.NotAfter) < generatedRenewBefore || !covers(cert, domain) {
+		return false
+	}
+
+	if opts.CA != nil {
+		return cert.CheckSignatureFrom(opts.CA.Cert) == nil
+	}
+
+	return matcher.IsSelfSigned(cert)
+}
+
+// generateMissing replaces the pairs below opts.GenerateMissing.Dir with
+// pairs for the listed domains not covered by any other pair, reusing the
+// certificates generated by previous runs while they are valid. The files of
+// other domains are removed by the cleanup added to result. Without write,
+// no certificate is issued and the directory is left as it is, only the
+// reusable certificates are used.
+func generateMissing(ctx context.Context, opts *Options, result *Result, write bool) error {
+	gen := opts.GenerateMissing
+	dir := filepath.Clean(gen.Dir)
+
+	var pairs []matcher.KeyPair
+
+	for _, pair := range result.Pairs {
+		if !strings.HasPrefix(pair.CertPath, dir+string(filepath.Separator)) {
+			pairs = append(pairs, pair)
+		}
+	}
+
+	files := map[string][]byte{}
+
+	var paths []string
+
+	for _, domain := range gen.Domains {
+		if coveredBy(pairs, domain) {
+			continue
+		}
+
+		name := generatedName(domain)
+		certFile, keyFile := name+".crt", name+".key"
+
+		certPEM, certErr := ioutil.ReadFile(filepath.Join(dir, certFile))
+		keyPEM, keyErr := ioutil.ReadFile(filepath.Join(dir, keyFile))
+
+		if certErr != nil || keyErr != nil || !reusable(certPEM, domain, gen) {
+			if !write {
+				log.WithField("domain", domain).Info("Certificate for missing domain would be generated")
+				continue
+			}
+
+			var err error
+
+			certPEM, keyPEM, err = certgen.Issue([]string{domain}, gen.Validity, gen.CA)
+			if err != nil {
+				return err
+			}
+
+			log.WithField("domain", domain).Info("Generated certificate for missing domain")
+		}
+
+		files[certFile], files[keyFile] = certPEM, keyPEM
+		paths = append(paths, filepath.Join(dir, certFile), filepath.Join(dir, keyFile))
+	}
+
+	if write {
+		c, err := materialize(dir, files)
+		if err != nil {
+			return err
+		}
+
+		result.cleanups = append(result.cleanups, c)
+	}
+
+	result.Pairs = pairs
+
+	if len(files) == 0 {
+		return nil
+	}
+
+	scan, err := scanner.Scan(ctx, paths, &opts.Load)
+	if err != nil {
+		return err
+	}
+
+	result.Certs += len(scan.Certs) + len(scan.Combined)
+	result.Keys += len(scan.Keys) + len(scan.Combined)
+	result.Pairs = append(result.Pairs, matcher.Match(scan)...)
+
+	matcher.Sort(result.Pairs)
+
+	return nil
+}
@@ -0,0 +1,247 @@
+package tlsconfig
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/chrisxf/traefik-tls-config-gen/matcher"
+	"github.com/chrisxf/traefik-tls-config-gen/render"
+	log "github.com/sirupsen/logrus"
+)
+
+// clientCAName is the file of the client CA options in the output
+// directory.
+const clientCAName = "client-ca"
+
+// manifestName is the file in the output directory listing the configs
+// written there, so the files of dropped pairs can be removed without
+// touching hand-written configs.
+const manifestName = ".generated"
+
+var unsafeNameChars = regexp.MustCompile(`[^a-z0-9._-]+`)
+
+// pairFileName returns the file name without extension of pair in the
+// output directory, derived from the common name of its certificate.
+func pairFileName(pair matcher.KeyPair) string {
+	name := pair.Cert.Subject.CommonName
+	if name == "" && len(pair.Cert.DNSNames) > 0 {
+		name = pair.Cert.DNSNames[0]
+	}
+
+	if name == "" {
+		name = strings.TrimSuffix(filepath.Base(pair.CertPath), filepath.Ext(pair.CertPath))
+	}
+
+	name = strings.Replace(strings.ToLower(name), "*", "wildcard", -1)
+	name = strings.Trim(unsafeNameChars.ReplaceAllString(name, "_"), "._")
+
+	if name == "" || name == clientCAName {
+		name = "cert"
+	}
+
+	return name
+}
+
+// outDirExt returns the file extension of the configs of format.
+func outDirExt(format string, opts *render.Options) string {
+	switch format {
+	case "json", "traefik-json", "envoy-sds", "caddy":
+		return ".json"
+	case "haproxy":
+		return ".crt-list"
+	case "nginx", "nginx-snippet":
+		return ".conf"
+	case "compose-labels":
+		return ".yml"
+	case "template":
+		if ext := filepath.Ext(strings.TrimSuffix(opts.Template, ".tmpl")); ext != "" {
+			return ext
+		}
+	}
+
+	return ".toml"
+}
+
+// outDirFiles renders every pair into its own config file. The default
+// certificate is declared in the file of its pair, the client CAs get a file
+// of their own.
+func outDirFiles(pairs []matcher.KeyPair, opts *Options) (map[string][]byte, error) {
+	ext := outDirExt(opts.Format, &opts.Render)
+	defaultPair := render.DefaultPair(pairs, &opts.Render)
+
+	files := map[string][]byte{}
+
+	renderFile := func(name string, pairs []matcher.KeyPair, renderOpts render.Options) error {
+		renderer, err := render.New(opts.Format, &renderOpts)
+		if err != nil {
+			return err
+		}
+
+		block, err := renderer.Render(pairs)
+		if err != nil {
+			return err
+		}
+
+		unique := name
+		for i := 2; files[unique+ext] != nil; i++ {
+			unique = name + "-" + strconv.Itoa(i)
+		}
+
+		files[unique+ext] = render.Merge(nil, finishBlock(block, pairs, opts))
+
+		return nil
+	}
+
+	for _, pair := range pairs {
+		pairOpts := opts.Render
+		pairOpts.DefaultDomain, pairOpts.DefaultCert, pairOpts.CAFiles = "", "", nil
+
+		if defaultPair != nil && defaultPair.CertPath == pair.CertPath {
+			pairOpts.DefaultCert = pair.CertPath
+		}
+
+		err := renderFile(pairFileName(pair), []matcher.KeyPair{pair}, pairOpts)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(opts.Render.CAFiles) > 0 {
+		caOpts := opts.Render
+		caOpts.DefaultDomain, caOpts.DefaultCert = "", ""
+
+		err := renderFile(clientCAName, nil, caOpts)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return files, nil
+}
+
+// readManifest returns the names of the configs generated into dir, none if
+// the manifest does not exist yet.
+func readManifest(dir string) ([]string, error) {
+	content, err := ioutil.ReadFile(filepath.Join(dir, manifestName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, name := range strings.Split(string(content), "\n") {
+		if name != "" && name == filepath.Base(name) {
+			names = append(names, name)
+		}
+	}
+
+	return names, nil
+}
+
+// writeOutDir makes opts.OutDir hold a config file for every pair and
+// removes the configs it generated before for pairs that are gone. Files not
+// listed in the manifest are left alone. In check or dry run mode nothing is
+// written. It returns whether any file changed and the diff.
+func writeOutDir(pairs []matcher.KeyPair, opts *Options) (bool, string, error) {
+	files, err := outDirFiles(pairs, opts)
+	if err != nil {
+		return false, "", err
+	}
+
+	write := !opts.Check && !opts.DryRun
+
+	if write {
+		err = os.MkdirAll(opts.OutDir, 0755)
+		if err != nil {
+			return false, "", err
+		}
+	}
+
+	changed := false
+	diff := &strings.Builder{}
+
+	generated, err := readManifest(opts.OutDir)
+	if err != nil {
+		return false, "", err
+	}
+
+	for _, name := range generated {
+		if files[name] != nil {
+			continue
+		}
+
+		path := filepath.Join(opts.OutDir, name)
+
+		previous, err := ioutil.ReadFile(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+
+		if err != nil {
+			return false, "", err
+		}
+
+		changed = true
+		diff.WriteString(Diff(path, path+" (generated)", previous, nil))
+
+		if write {
+			log.WithField("path", path).Info("Removing config of dropped pair")
+
+			err = os.Remove(path)
+			if err != nil {
+				return false, "", err
+			}
+		}
+	}
+
+	var names []string
+	for name := range files {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		path := filepath.Join(opts.OutDir, name)
+		content := files[name]
+
+		previous, err := ioutil.ReadFile(path)
+		if err != nil && !os.IsNotExist(err) {
+			return false, "", err
+		}
+
+		if previous != nil && bytes.Equal(previous, content) {
+			continue
+		}
+
+		changed = true
+		diff.WriteString(Diff(path, path+" (generated)", previous, content))
+
+		if write {
+			log.WithField("path", path).Info("Writing config")
+
+			err = writeFileAtomic(path, content, 0644)
+			if err != nil {
+				return false, "", err
+			}
+		}
+	}
+
+	if write {
+		err = writeFileIfChanged(filepath.Join(opts.OutDir, manifestName), []byte(strings.Join(names, "\n")+"\n"), 0644)
+		if err != nil {
+			return false, "", err
+		}
+	}
+
+	return changed, diff.String(), nil
+}
@@ -0,0 +1,62 @@
+package tlsconfig
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/chrisxf/traefik-tls-config-gen/render"
+)
+
+func TestWriteOutDirKeepsForeignConfigs(t *testing.T) {
+	dir := t.TempDir()
+	outDir := t.TempDir()
+
+	for _, domain := range []string{"a.example.com", "b.example.com"} {
+		cert, keyDER := newPair(t, domain, time.Now().Add(time.Hour))
+
+		writePEM(t, filepath.Join(dir, domain, "cert.pem"), "CERTIFICATE", cert)
+		writePEM(t, filepath.Join(dir, domain, "privkey.pem"), "EC PRIVATE KEY", keyDER)
+	}
+
+	routers := filepath.Join(outDir, "routers.toml")
+
+	err := ioutil.WriteFile(routers, []byte("[http.routers]\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opts := Options{
+		Dirs:   []Dir{{Path: dir}},
+		OutDir: outDir,
+		Render: render.Options{TraefikVersion: 2},
+	}
+
+	_, err = Generate(context.Background(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = os.RemoveAll(filepath.Join(dir, "b.example.com"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = Generate(context.Background(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for path, want := range map[string]bool{
+		routers: true,
+		filepath.Join(outDir, "a.example.com.toml"): true,
+		filepath.Join(outDir, "b.example.com.toml"): false,
+	} {
+		if _, err := os.Stat(path); (err == nil) != want {
+			t.Errorf("%s exists: %v, want %v", path, err == nil, want)
+		}
+	}
+}
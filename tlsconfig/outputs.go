@@ -0,0 +1,80 @@
+package tlsconfig
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/chrisxf/traefik-tls-config-gen/render"
+	log "github.com/sirupsen/logrus"
+)
+
+// Output is an additional config file rendered from the same scan as Out.
+type Output struct {
+	Path string
+	// Format, PathPrefix and TraefikVersion default to the ones of Options.
+	Format         string
+	PathPrefix     string
+	TraefikVersion int
+}
+
+// writeOutputs renders the pairs of result into every opts.Outputs file and
+// writes the changed ones, unless opts.Check or opts.DryRun is set. It
+// returns whether any changed and their diff.
+func writeOutputs(result *Result, opts *Options) (bool, string, error) {
+	changed := false
+	diff := &strings.Builder{}
+
+	for _, output := range opts.Outputs {
+		renderOpts := opts.Render
+		if output.PathPrefix != "" {
+			renderOpts.PathPrefix = output.PathPrefix
+		}
+
+		if output.TraefikVersion != 0 {
+			renderOpts.TraefikVersion = output.TraefikVersion
+		}
+
+		format := output.Format
+		if format == "" {
+			format = opts.Format
+		}
+
+		renderer, err := render.New(format, &renderOpts)
+		if err != nil {
+			return false, "", err
+		}
+
+		previous, content, err := renderConfigFile(result.Pairs, output.Path, renderer, opts)
+		if err != nil {
+			return false, "", err
+		}
+
+		if previous != nil && bytes.Equal(previous, content) {
+			log.WithField("path", output.Path).Debug("Config unchanged, skipping write")
+			continue
+		}
+
+		if !opts.DryRun && !opts.NoValidate {
+			err = validateConfig(output.Path, format, content, result, &renderOpts)
+			if err != nil {
+				return false, "", err
+			}
+		}
+
+		changed = true
+		diff.WriteString(Diff(output.Path, output.Path+" (generated)", previous, content))
+
+		if opts.Check || opts.DryRun {
+			continue
+		}
+
+		log.WithField("path", output.Path).Info("Writing config")
+
+		err = writeConfig(output.Path, content, opts.Backup)
+		if err != nil {
+			return false, "", err
+		}
+	}
+
+	return changed, diff.String(), nil
+}
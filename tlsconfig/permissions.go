@@ -0,0 +1,148 @@
+package tlsconfig
+
+import (
+	"errors"
+	"os"
+	"os/user"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/chrisxf/traefik-tls-config-gen/matcher"
+	log "github.com/sirupsen/logrus"
+)
+
+// Modes set by FixPermissions.
+const (
+	KeyFileMode  os.FileMode = 0600
+	CertFileMode os.FileMode = 0644
+)
+
+// PermissionOptions configure the permission check of the files of the
+// pairs.
+type PermissionOptions struct {
+	// Fix sets the mode of key files to KeyFileMode and of cert files to
+	// CertFileMode instead of only logging readable keys.
+	Fix bool
+	// Owner is "user" or "user:group", names or ids, the files are changed
+	// to if Fix is set.
+	Owner string
+}
+
+// lookupOwner returns the uid and gid of owner, -1 for the parts not given.
+func lookupOwner(owner string) (int, int, error) {
+	uid, gid := -1, -1
+
+	if owner == "" {
+		return uid, gid, nil
+	}
+
+	parts := strings.SplitN(owner, ":", 2)
+
+	if parts[0] != "" {
+		id, err := strconv.Atoi(parts[0])
+		if err != nil {
+			u, err := user.Lookup(parts[0])
+			if err != nil {
+				return 0, 0, err
+			}
+
+			id, err = strconv.Atoi(u.Uid)
+			if err != nil {
+				return 0, 0, errors.New("user " + parts[0] + " has no numeric id")
+			}
+		}
+
+		uid = id
+	}
+
+	if len(parts) == 2 && parts[1] != "" {
+		id, err := strconv.Atoi(parts[1])
+		if err != nil {
+			g, err := user.LookupGroup(parts[1])
+			if err != nil {
+				return 0, 0, err
+			}
+
+			id, err = strconv.Atoi(g.Gid)
+			if err != nil {
+				return 0, 0, errors.New("group " + parts[1] + " has no numeric id")
+			}
+		}
+
+		gid = id
+	}
+
+	return uid, gid, nil
+}
+
+// checkPermissions logs the key files of pairs readable by group or others
+// and, with opts.Fix, sets the modes and owner of the cert and key files.
+// The unwritten files are skipped, as are file modes on Windows.
+func checkPermissions(pairs []matcher.KeyPair, opts *PermissionOptions, unwritten map[string]bool) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+
+	uid, gid, err := lookupOwner(opts.Owner)
+	if err != nil {
+		return err
+	}
+
+	var paths []string
+
+	modes := map[string]os.FileMode{}
+	for _, pair := range pairs {
+		if _, ok := modes[pair.CertPath]; !ok {
+			paths = append(paths, pair.CertPath)
+			modes[pair.CertPath] = CertFileMode
+		}
+
+		if _, ok := modes[pair.KeyPath]; !ok {
+			paths = append(paths, pair.KeyPath)
+		}
+
+		modes[pair.KeyPath] = KeyFileMode
+	}
+
+	for _, path := range paths {
+		if unwritten[path] {
+			continue
+		}
+
+		mode := modes[path]
+
+		info, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+
+		logger := log.WithFields(log.Fields{"path": path, "mode": info.Mode().Perm().String()})
+
+		if !opts.Fix {
+			if mode == KeyFileMode && info.Mode().Perm()&0077 != 0 {
+				logger.Warn("Private key file is accessible by group or others")
+			}
+
+			continue
+		}
+
+		if info.Mode().Perm() != mode {
+			logger.WithField("newMode", mode.String()).Info("Fixing file permissions")
+
+			err = os.Chmod(path, mode)
+			if err != nil {
+				return err
+			}
+		}
+
+		if uid != -1 || gid != -1 {
+			err = os.Chown(path, uid, gid)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,60 @@
+package tlsconfig
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// PushOptions configure pushing the config to the Traefik REST provider.
+type PushOptions struct {
+	// URL is the REST provider endpoint, e.g.
+	// http://traefik:8080/api/providers/rest.
+	URL string
+	// Username and Password enable basic auth, Token bearer auth.
+	Username string
+	Password string
+	Token    string
+}
+
+// push PUTs the JSON config to the REST provider endpoint.
+func push(ctx context.Context, opts *PushOptions, content []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, opts.URL, bytes.NewReader(content))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	if opts.Username != "" {
+		req.SetBasicAuth(opts.Username, opts.Password)
+	}
+
+	if opts.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+opts.Token)
+	}
+
+	log.WithField("url", opts.URL).Info("Pushing config to REST provider")
+
+	client := &http.Client{Timeout: 30 * time.Second}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return errors.New("REST provider returned " + resp.Status + ": " + string(bytes.TrimSpace(body)))
+	}
+
+	return nil
+}
@@ -0,0 +1,136 @@
+package tlsconfig
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+
+	"github.com/chrisxf/traefik-tls-config-gen/matcher"
+	"github.com/chrisxf/traefik-tls-config-gen/revocation"
+	"github.com/chrisxf/traefik-tls-config-gen/scanner"
+	"golang.org/x/crypto/ocsp"
+)
+
+// RevocationOptions configure the revocation checks of the pairs.
+type RevocationOptions struct {
+	// OCSP queries the responders of the certificates.
+	OCSP bool
+	// CRLs are files or http(s) URLs of revocation lists the certificates
+	// are looked up in, before querying OCSP.
+	CRLs []string
+	// Exclude drops revoked pairs instead of only logging them.
+	Exclude bool
+}
+
+// issuerOf returns the certificate that signed the certificate of pair, from
+// its chain or the intermediates found.
+func issuerOf(pair matcher.KeyPair, intermediates []*x509.Certificate) *x509.Certificate {
+	for _, candidates := range [][]*x509.Certificate{pair.Chain, intermediates} {
+		for _, candidate := range candidates {
+			if bytes.Equal(pair.Cert.RawIssuer, candidate.RawSubject) && pair.Cert.CheckSignatureFrom(candidate) == nil {
+				return candidate
+			}
+		}
+	}
+
+	return nil
+}
+
+// checkRevocation logs the revoked pairs and drops them if opts.Exclude is
+// set. Pairs whose status cannot be determined are kept.
+func checkRevocation(ctx context.Context, result *Result, opts *RevocationOptions, client *revocation.OCSPClient) error {
+	crls, err := revocation.LoadCRLs(ctx, opts.CRLs)
+	if err != nil {
+		return err
+	}
+
+	var pairs []matcher.KeyPair
+
+	for _, pair := range result.Pairs {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		logger := scanner.CertLogger(pair.CertPath, pair.Cert)
+		issuer := issuerOf(pair, result.Intermediates)
+
+		revoked := false
+
+		if entry := revocation.CheckCRLs(crls, pair.Cert, issuer); entry != nil {
+			revoked = true
+			logger = logger.WithField("revokedAt", entry.RevocationTime)
+		} else if opts.OCSP && len(pair.Cert.OCSPServer) > 0 {
+			if issuer == nil {
+				logger.Warn("Issuer not found, cannot check OCSP status")
+				pairs = append(pairs, pair)
+				continue
+			}
+
+			response, _, err := client.Fetch(ctx, pair.Cert, issuer)
+			if err != nil {
+				logger.WithError(err).Warn("Could not check OCSP status")
+				pairs = append(pairs, pair)
+				continue
+			}
+
+			switch response.Status {
+			case ocsp.Revoked:
+				revoked = true
+				logger = logger.WithField("revokedAt", response.RevokedAt)
+			case ocsp.Unknown:
+				logger.Warn("Certificate unknown to OCSP responder")
+			default:
+				logger.Debug("OCSP status good")
+			}
+		}
+
+		if revoked {
+			result.Revoked++
+
+			if opts.Exclude {
+				logger.Error("Certificate revoked, skipping pair")
+				continue
+			}
+
+			logger.Error("Certificate revoked")
+		}
+
+		pairs = append(pairs, pair)
+	}
+
+	result.Pairs = pairs
+
+	return nil
+}
+
+// writeStaples writes the OCSP response of every pair next to its
+// certificate file, with the .ocsp extension. Pairs without responder are
+// skipped, failed fetches keep the previous response.
+func writeStaples(ctx context.Context, result *Result, client *revocation.OCSPClient) error {
+	for _, pair := range result.Pairs {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		logger := scanner.CertLogger(pair.CertPath, pair.Cert)
+
+		issuer := issuerOf(pair, result.Intermediates)
+		if len(pair.Cert.OCSPServer) == 0 || issuer == nil {
+			logger.Debug("No OCSP responder or issuer, skipping staple")
+			continue
+		}
+
+		_, raw, err := client.Fetch(ctx, pair.Cert, issuer)
+		if err != nil {
+			logger.WithError(err).Warn("Could not fetch OCSP response")
+			continue
+		}
+
+		err = writeFileIfChanged(pair.CertPath+".ocsp", raw, 0644)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,354 @@
+package tlsconfig
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/chrisxf/traefik-tls-config-gen/aws"
+	"github.com/chrisxf/traefik-tls-config-gen/azure"
+	"github.com/chrisxf/traefik-tls-config-gen/gcp"
+	"github.com/chrisxf/traefik-tls-config-gen/kube"
+	"github.com/chrisxf/traefik-tls-config-gen/vault"
+	log "github.com/sirupsen/logrus"
+)
+
+// CertEntry is a PEM encoded certificate and private key of a Source. One of
+// them may be empty if the source stores them separately, the scanner pairs
+// them like any other files.
+type CertEntry struct {
+	// Name identifies the entry within the source and becomes its file
+	// name.
+	Name string
+	Cert []byte
+	Key  []byte
+}
+
+// Source provides certificates and keys stored outside the scanned
+// directories.
+type Source interface {
+	List(ctx context.Context) ([]CertEntry, error)
+}
+
+// SourceDir is a Source and the directory its entries are written to before
+// every scan. The directory is managed by the tool, other .crt and .key files
+// in it are deleted.
+type SourceDir struct {
+	Source Source
+	Dir    string
+}
+
+// KubeSource lists the kubernetes.io/tls secrets of a cluster.
+type KubeSource struct {
+	Client *kube.Client
+	// Namespace defaults to the namespace of the client config.
+	Namespace string
+	// Selector is a label selector limiting the secrets read.
+	Selector string
+}
+
+func (s *KubeSource) List(ctx context.Context) ([]CertEntry, error) {
+	namespace := s.Namespace
+	if namespace == "" {
+		namespace = s.Client.Namespace()
+	}
+
+	secrets, err := s.Client.ListSecrets(ctx, namespace, "kubernetes.io/tls", s.Selector)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []CertEntry
+
+	for _, secret := range secrets {
+		cert, key := secret.Data["tls.crt"], secret.Data["tls.key"]
+		if len(cert) == 0 || len(key) == 0 {
+			log.WithFields(log.Fields{"namespace": namespace, "secret": secret.Metadata.Name}).Warn("Secret without certificate or key")
+			continue
+		}
+
+		entries = append(entries, CertEntry{Name: namespace + "_" + secret.Metadata.Name, Cert: cert, Key: key})
+	}
+
+	return entries, nil
+}
+
+// VaultSource lists the certificates and keys stored below a path of a
+// Vault KV engine.
+type VaultSource struct {
+	Vault vault.Options
+	// Mount is the mount of the KV engine, Path the prefix below it.
+	Mount string
+	Path  string
+	// KVVersion is the version of the KV engine, 2 if zero.
+	KVVersion int
+}
+
+func (s *VaultSource) List(ctx context.Context) ([]CertEntry, error) {
+	client, err := vault.NewClient(ctx, &s.Vault)
+	if err != nil {
+		return nil, err
+	}
+
+	version := s.KVVersion
+	if version == 0 {
+		version = 2
+	}
+
+	pairs, err := client.ReadPairs(ctx, s.Mount, s.Path, version)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []CertEntry
+
+	for _, pair := range pairs {
+		entries = append(entries, CertEntry{Name: strings.Replace(pair.Path, "/", "_", -1), Cert: pair.Cert, Key: pair.Key})
+	}
+
+	return entries, nil
+}
+
+// SecretsManagerSource lists the certificates and keys stored in AWS
+// Secrets Manager.
+type SecretsManagerSource struct {
+	AWS aws.Options
+	// Prefix and Tag, as KEY or KEY=VALUE, select the secrets read.
+	Prefix string
+	Tag    string
+}
+
+func (s *SecretsManagerSource) List(ctx context.Context) ([]CertEntry, error) {
+	client, err := aws.NewClient(ctx, &s.AWS)
+	if err != nil {
+		return nil, err
+	}
+
+	pairs, err := client.ReadSecretPairs(ctx, s.Prefix, s.Tag)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []CertEntry
+
+	for _, pair := range pairs {
+		entries = append(entries, CertEntry{Name: strings.Replace(pair.Name, "/", "_", -1), Cert: pair.Cert, Key: pair.Key})
+	}
+
+	return entries, nil
+}
+
+// S3Source lists the PEM files below a prefix of an S3 bucket. Files holding
+// a private key become keys, all others certificates.
+type S3Source struct {
+	AWS    aws.Options
+	Bucket string
+	Prefix string
+}
+
+func (s *S3Source) List(ctx context.Context) ([]CertEntry, error) {
+	client, err := aws.NewClient(ctx, &s.AWS)
+	if err != nil {
+		return nil, err
+	}
+
+	objects, err := client.ReadObjects(ctx, s.Bucket, s.Prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []CertEntry
+
+	for _, object := range objects {
+		name := strings.Trim(strings.TrimPrefix(object.Key, s.Prefix), "/")
+		entry := CertEntry{Name: strings.Replace(strings.TrimSuffix(name, filepath.Ext(name)), "/", "_", -1)}
+
+		if bytes.Contains(object.Content, []byte("PRIVATE KEY-----")) {
+			entry.Key = object.Content
+		} else {
+			entry.Cert = object.Content
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// AzureSource lists the certificates of an Azure Key Vault.
+type AzureSource struct {
+	// Vault is the name or URL of the vault.
+	Vault string
+	// Tag, as KEY or KEY=VALUE, selects the certificates read.
+	Tag string
+}
+
+func (s *AzureSource) List(ctx context.Context) ([]CertEntry, error) {
+	client, err := azure.NewClient(ctx, s.Vault)
+	if err != nil {
+		return nil, err
+	}
+
+	pairs, err := client.ReadPairs(ctx, s.Tag)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []CertEntry
+
+	for _, pair := range pairs {
+		entries = append(entries, CertEntry{Name: pair.Name, Cert: pair.Cert, Key: pair.Key})
+	}
+
+	return entries, nil
+}
+
+// GCPSource lists the certificates and keys stored in Google Cloud Secret
+// Manager.
+type GCPSource struct {
+	GCP gcp.Options
+	// Filter selects the secrets read.
+	Filter string
+}
+
+func (s *GCPSource) List(ctx context.Context) ([]CertEntry, error) {
+	client, err := gcp.NewClient(ctx, &s.GCP)
+	if err != nil {
+		return nil, err
+	}
+
+	pairs, err := client.ReadPairs(ctx, s.Filter)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []CertEntry
+
+	for _, pair := range pairs {
+		entries = append(entries, CertEntry{Name: pair.Name, Cert: pair.Cert, Key: pair.Key})
+	}
+
+	return entries, nil
+}
+
+// syncSource writes the entries of the source into its directory and returns
+// the cleanup removing the files of entries that are gone.
+func syncSource(ctx context.Context, source SourceDir) (cleanup, error) {
+	entries, err := source.Source.List(ctx)
+	if err != nil {
+		return cleanup{}, err
+	}
+
+	log.WithFields(log.Fields{"dir": source.Dir, "entries": len(entries)}).Info("Read certificates from source")
+
+	files := map[string][]byte{}
+
+	for _, entry := range entries {
+		if len(entry.Cert) > 0 {
+			files[entry.Name+".crt"] = entry.Cert
+		}
+
+		if len(entry.Key) > 0 {
+			files[entry.Name+".key"] = entry.Key
+		}
+	}
+
+	return materialize(source.Dir, files)
+}
+
+// materialize writes files, which maps file names to their content, to dir
+// and returns the cleanup removing the other .crt and .key files from it.
+// Keys are only readable by the owner.
+func materialize(dir string, files map[string][]byte) (cleanup, error) {
+	if dir == "" {
+		return cleanup{}, errors.New("directory for source files must be set")
+	}
+
+	err := os.MkdirAll(dir, 0700)
+	if err != nil {
+		return cleanup{}, err
+	}
+
+	keep := map[string]bool{}
+
+	for name, content := range files {
+		perm := os.FileMode(0644)
+		if filepath.Ext(name) == ".key" {
+			perm = 0600
+		}
+
+		err := writeFileIfChanged(filepath.Join(dir, name), content, perm)
+		if err != nil {
+			return cleanup{}, err
+		}
+
+		keep[name] = true
+	}
+
+	return cleanup{Dir: dir, Keep: keep, Exts: []string{".crt", ".key"}}, nil
+}
+
+// cleanup is the removal of the files in Dir with one of Exts not in Keep,
+// which is only done once the config no longer refers to them.
+type cleanup struct {
+	Dir  string
+	Keep map[string]bool
+	Exts []string
+}
+
+// removeStale runs the cleanups.
+func removeStale(cleanups []cleanup) error {
+	for _, c := range cleanups {
+		err := prune(c.Dir, c.Keep, c.Exts...)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// prune removes the files in dir with one of the extensions not in keep.
+func prune(dir string, keep map[string]bool, exts ...string) error {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		prunable := false
+
+		for _, ext := range exts {
+			prunable = prunable || filepath.Ext(name) == ext
+		}
+
+		if entry.IsDir() || keep[name] || !prunable || strings.HasPrefix(name, ".") {
+			continue
+		}
+
+		path := filepath.Join(dir, name)
+		log.WithField("path", path).Info("Removing file no longer in source")
+
+		err := os.Remove(path)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeFileIfChanged writes data atomically unless path already holds it, so
+// the modification time and the scan cache stay valid.
+func writeFileIfChanged(path string, data []byte, perm os.FileMode) error {
+	if current, err := ioutil.ReadFile(path); err == nil && string(current) == string(data) {
+		return nil
+	}
+
+	return writeFileAtomic(path, data, perm)
+}
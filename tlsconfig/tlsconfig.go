@@ -0,0 +1,962 @@
+// Package tlsconfig runs the whole scan, match and render pipeline used by
+// the traefik-tls-config-gen command, so it can be embedded in other tools.
+package tlsconfig
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/chrisxf/traefik-tls-config-gen/kv"
+	"github.com/chrisxf/traefik-tls-config-gen/matcher"
+	"github.com/chrisxf/traefik-tls-config-gen/render"
+	"github.com/chrisxf/traefik-tls-config-gen/revocation"
+	"github.com/chrisxf/traefik-tls-config-gen/scanner"
+	log "github.com/sirupsen/logrus"
+)
+
+// StampOptions configure the metadata comment of the config.
+type StampOptions struct {
+	Version string
+	// OmitTime leaves out the generation time, so the content only changes
+	// with the pairs.
+	OmitTime bool
+}
+
+// Dir is a certificate directory to scan.
+type Dir struct {
+	Path string
+	// PathPrefix, if set, replaces the render path prefix for files below
+	// the directory.
+	PathPrefix string
+}
+
+type Options struct {
+	// Dirs are the certificate directories to scan.
+	Dirs []Dir
+	// Out is the config file to write. It may be empty if Push is set.
+	Out string
+	// OutDir, if set, receives a config file per pair, for Traefik's file
+	// provider watching a directory. The configs it generated for pairs
+	// that are gone are removed, other files are left alone.
+	OutDir string
+	// Format selects the registered renderer, "traefik" if empty.
+	Format string
+	// Outputs are written in addition to Out, each with its own format.
+	Outputs []Output
+	// Stamp, if set, adds a comment block describing the run below the
+	// header of the formats with comments.
+	Stamp *StampOptions
+	// NoHeader leaves out the header and footer, the whole file is replaced
+	// then instead of the generated block.
+	NoHeader bool
+	// Backup, if set, keeps copies of Out and Outputs before they are
+	// overwritten.
+	Backup *BackupOptions
+	// MinPairs and MaxRemovedPercent, if set, make Generate and
+	// UpdateLineage return a ShrinkError instead of writing a config with
+	// fewer pairs or with more of the pairs of Out removed.
+	MinPairs          int
+	MaxRemovedPercent int
+	// LockFile is locked while Generate or UpdateLineage write, defaulting to
+	// Out with ".lock" appended or ".lock" in OutDir. NoLock disables the
+	// lock.
+	LockFile string
+	NoLock   bool
+	// NoValidate skips parsing changed Traefik configs back and checking
+	// them before they are written.
+	NoValidate bool
+	// VerifyPaths warns about paths written into the config that do not
+	// exist below VerifyRoot, or as is if it is empty.
+	VerifyPaths bool
+	VerifyRoot  string
+	// Check only compares the generated config with Out and sets Diff
+	// instead of writing it.
+	Check bool
+	// DryRun sets Content instead of writing the config. Out may be empty.
+	DryRun bool
+	// ReturnContent sets Content like DryRun, but the converted files, the
+	// copies and the generated certificates are written as usual, e.g. to
+	// serve the config. Out may be empty.
+	ReturnContent bool
+
+	Walk   scanner.WalkOptions
+	Load   scanner.Options
+	Chain  matcher.ChainOptions
+	Render render.Options
+	// Push, if set, sends the config to the Traefik REST provider on every
+	// run, in addition to writing Out.
+	Push *PushOptions
+	// KV, if set, writes the config into a key-value store for Traefik's
+	// KV providers on every run.
+	KV *KVOptions
+	// Sources are listed before every scan and their entries written to
+	// their directories, which are scanned in addition to Dirs. The files of
+	// entries that are gone are only removed once Generate wrote the config.
+	Sources []SourceDir
+	// Kube, if set, applies the pairs as TLS secrets to a cluster on every
+	// run.
+	Kube *KubeOptions
+
+	// StateFile, if set, caches the scan results between runs, see
+	// scanner.Cache.
+	StateFile string
+
+	// CopyTo, if set, receives the cert and key of every pair under names
+	// derived from the certificate, and the config points at them. CopyMode
+	// is CopyModeCopy, CopyModeHardlink or CopyModeBundle.
+	CopyTo   string
+	CopyMode string
+
+	// SplitCombinedDir, if set, receives separate cert and key files for
+	// combined cert+key files.
+	SplitCombinedDir string
+
+	// OnlyDomains and SkipDomains filter pairs by the domains of their
+	// certificate, see matcher.FilterDomains.
+	OnlyDomains []string
+	SkipDomains []string
+
+	// Prefer is the policy for pairs covering the same domains, see
+	// matcher.ResolveDuplicates.
+	Prefer string
+
+	// RequireServerAuth drops the pairs whose certificate is not meant for
+	// TLS servers by its key usage, they are only logged otherwise.
+	RequireServerAuth bool
+
+	// Policy is the action for pairs with weak keys, weak signatures or a
+	// validity longer than MaxValidity, see matcher.CheckPolicy.
+	Policy      string
+	MaxValidity time.Duration
+
+	// RequireDomains are the hostnames that must be covered by a pair, see
+	// Result.MissingDomains.
+	RequireDomains []string
+
+	// SelfSigned is the handling of pairs with a self-signed certificate,
+	// see matcher.FilterSelfSigned. Generated certificates are exempt.
+	SelfSigned string
+
+	// VerifyChain verifies the chain of every pair against the system roots,
+	// or the certificates of CABundle if set, and logs the pairs failing.
+	// ExcludeUnverifiable drops them instead.
+	VerifyChain         bool
+	CABundle            string
+	ExcludeUnverifiable bool
+
+	// Revocation, if set, checks whether the certificates were revoked.
+	Revocation *RevocationOptions
+	// OCSPStaple writes the OCSP response of every pair next to its
+	// certificate file for proxies loading pre-fetched staples.
+	OCSPStaple bool
+	// OCSPCacheDir caches good OCSP responses between runs, a directory
+	// below the user cache directory if empty.
+	OCSPCacheDir string
+
+	// Permissions configure the check of the modes of the cert and key
+	// files, which are always checked.
+	Permissions PermissionOptions
+
+	// GenerateMissing, if set, creates certificates for the listed domains
+	// no pair covers.
+	GenerateMissing *GenerateOptions
+
+	// MTLS adds CA certificates without a private key as client CAs. If
+	// ClientCADir is set, only CAs below it are used.
+	MTLS        bool
+	ClientCADir string
+
+	WarnDays int
+	FailDays int
+}
+
+// Orphan is a certificate or private key that could not be paired.
+type Orphan struct {
+	Path   string
+	Reason string
+}
+
+type Result struct {
+	// Files is the number of files found in the directories.
+	Files int
+	// Scanned holds every certificate and key found, matched or not.
+	Scanned *scanner.Result
+	// Matched holds all pairs found, before the domain filters and the
+	// duplicate resolution.
+	Matched       []matcher.KeyPair
+	Pairs         []matcher.KeyPair
+	Intermediates []*x509.Certificate
+	CAFiles       []string
+	Certs         int
+	Keys          int
+	OrphanKeys    int
+	// Orphans lists leaf certificates without a key and keys without a
+	// certificate.
+	Orphans []Orphan
+	// ScanErrors is the number of files that could not be loaded, not
+	// counting the expired certificates.
+	ScanErrors int
+	// Expired is the number of expired certificates left out of the scan.
+	Expired int
+	// Invalid is the number of pairs failing chain verification, only set
+	// by Verify or with VerifyChain.
+	Invalid int
+	// Revoked is the number of revoked certificates found by the revocation
+	// checks, excluded or not.
+	Revoked  int
+	Duration time.Duration
+	Changed  bool
+	// Diff is the unified diff between Out and the generated config, set
+	// when an existing config changed or in check mode.
+	Diff string
+	// DroppedDomains are the domains of the previous config missing from
+	// the new one. They are taken from the certificate annotations, so
+	// configs written with NoAnnotations yield none.
+	DroppedDomains []string
+	// Pushed is set if the config was sent to the REST provider.
+	Pushed bool
+	// Content is the generated config, only set in dry run mode or with
+	// ReturnContent.
+	Content      []byte
+	ExpiryFailed bool
+	// MissingDomains are the domains of Options.RequireDomains no pair
+	// covers.
+	MissingDomains []string
+
+	// copies are the files of Pairs in Options.CopyTo still to be written.
+	copies []pairCopy
+	// managed are the files of Pairs derived from the scanned files that are
+	// not written yet.
+	managed []scanner.ManagedFile
+	// cleanups remove the stale files of the managed directories once the
+	// config is written.
+	cleanups []cleanup
+}
+
+// sourceFiles maps the paths of the planned copies to the files they are
+// copied from.
+func (r *Result) sourceFiles() map[string]string {
+	files := map[string]string{}
+
+	for _, c := range r.copies {
+		files[c.KeyPath] = c.Source.KeyPath
+		files[c.CertPath] = c.Source.CertPath
+	}
+
+	return files
+}
+
+// unwritten returns the paths of the managed files not written yet.
+func (r *Result) unwritten() map[string]bool {
+	paths := map[string]bool{}
+
+	for _, file := range r.managed {
+		paths[file.Path] = true
+	}
+
+	return paths
+}
+
+// cacheDir returns the directory name below the user cache directory.
+func cacheDir(name string) string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+
+	return filepath.Join(dir, "traefik-tls-config-gen", name)
+}
+
+// findFiles returns the files below all dirs, each file only once.
+func findFiles(ctx context.Context, dirs []Dir, opts *scanner.WalkOptions) ([]string, error) {
+	var files []string
+
+	seen := map[string]bool{}
+
+	for _, dir := range dirs {
+		var dirFiles []string
+
+		err := scanner.FindFiles(ctx, filepath.Join(dir.Path, "."), opts, &dirFiles)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, file := range dirFiles {
+			if !seen[file] {
+				seen[file] = true
+				files = append(files, file)
+			}
+		}
+	}
+
+	return files, nil
+}
+
+// Scan searches opts.Dirs for certificates and private keys, matches them and
+// completes their chains, without writing the config. Only the entries of
+// opts.Sources are written, the converted DER and PKCS#12 files, the copies of
+// opts.CopyTo, the split files of opts.SplitCombinedDir and the full chains
+// of opts.Chain.Dir are only planned, missing certificates and OCSP staples
+// are left out and permissions are only checked.
+func Scan(ctx context.Context, opts Options) (Result, error) {
+	return scan(ctx, opts, false)
+}
+
+// scan is Scan, which with write also generates the missing certificates,
+// writes the managed files and fixes permissions. The copies and staples are
+// made by writePairFiles, the stale files of opts.Sources and
+// opts.GenerateMissing are removed by removeStale with the cleanups of the
+// result.
+func scan(ctx context.Context, opts Options, write bool) (Result, error) {
+	start := time.Now()
+
+	var result Result
+
+	for _, source := range opts.Sources {
+		c, err := syncSource(ctx, source)
+		if err != nil {
+			return result, err
+		}
+
+		result.cleanups = append(result.cleanups, c)
+
+		opts.Dirs = append(append([]Dir{}, opts.Dirs...), Dir{Path: source.Dir})
+	}
+
+	if len(opts.Dirs) == 0 {
+		return result, errors.New("certificate directory must be set")
+	}
+
+	files, err := findFiles(ctx, opts.Dirs, &opts.Walk)
+	if err != nil {
+		return result, err
+	}
+
+	log.WithField("files", len(files)).Info("Searching for certificates and private keys")
+
+	result.Files = len(files)
+
+	if err := ctx.Err(); err != nil {
+		return result, err
+	}
+
+	if opts.StateFile != "" && opts.Load.Cache == nil {
+		opts.Load.Cache = scanner.LoadCache(opts.StateFile)
+	}
+
+	scan, err := scanner.Scan(ctx, files, &opts.Load)
+	if err != nil {
+		return result, err
+	}
+
+	if opts.StateFile != "" {
+		if err := opts.Load.Cache.Save(); err != nil {
+			log.WithFields(log.Fields{"path": opts.StateFile, "error": err}).Warn("Could not write state file")
+		}
+	}
+
+	result.Scanned = scan
+	result.managed = append(result.managed, scan.Managed...)
+	result.Certs = len(scan.Certs) + len(scan.Combined)
+	result.Keys = len(scan.Keys) + len(scan.Combined)
+
+	for _, fileErr := range scan.Errors {
+		if fileErr.Err == scanner.ErrExpired {
+			result.Expired++
+		} else {
+			result.ScanErrors++
+		}
+	}
+
+	if result.Certs == 0 && result.Keys == 0 && opts.GenerateMissing == nil {
+		result.MissingDomains = missingDomains(nil, opts.RequireDomains)
+		result.Duration = time.Since(start)
+		return result, nil
+	}
+
+	result.Intermediates = matcher.Intermediates(scan.Certs)
+	result.Pairs = matcher.Match(scan)
+	result.Matched = append([]matcher.KeyPair{}, result.Pairs...)
+
+	// Keys are matched by fingerprint, so a copy of a paired key at another
+	// path is not reported as orphan.
+	matchedKeys := map[[sha256.Size]byte]bool{}
+	matchedCerts := map[string]bool{}
+	for _, pair := range result.Pairs {
+		if fingerprint, err := scanner.KeyFingerprint(pair.Cert.PublicKey); err == nil {
+			matchedKeys[fingerprint] = true
+		}
+
+		matchedCerts[pair.CertPath] = true
+	}
+
+	// The keys of expired certificates are left out with them.
+	for _, fileErr := range scan.Errors {
+		if fileErr.Err != scanner.ErrExpired || fileErr.Cert == nil {
+			continue
+		}
+
+		if fingerprint, err := scanner.KeyFingerprint(fileErr.Cert.PublicKey); err == nil {
+			matchedKeys[fingerprint] = true
+		}
+	}
+
+	for _, pub := range scan.Certs {
+		if matchedCerts[pub.Path] {
+			continue
+		}
+
+		if pub.Cert.IsCA {
+			result.CAFiles = append(result.CAFiles, pub.Path)
+			continue
+		}
+
+		scanner.CertLogger(pub.Path, pub.Cert).Warn("Certificate without private key")
+		result.Orphans = append(result.Orphans, Orphan{Path: pub.Path, Reason: "no matching private key"})
+	}
+
+	for _, key := range scan.Keys {
+		fingerprint, err := scanner.KeyFingerprint(key.Key)
+		if err != nil || !matchedKeys[fingerprint] {
+			log.WithField("path", key.Path).Warn("Private key without certificate")
+			result.Orphans = append(result.Orphans, Orphan{Path: key.Path, Reason: "no matching certificate"})
+			result.OrphanKeys++
+		}
+	}
+
+	// Filter after the orphan detection, so filtered pairs are not reported.
+	result.Pairs = matcher.FilterDomains(result.Pairs, opts.OnlyDomains, opts.SkipDomains)
+
+	// Before the duplicate resolution, so a usable pair wins over a client
+	// certificate for the same domains.
+	result.Pairs = matcher.CheckUsage(result.Pairs, opts.RequireServerAuth)
+
+	result.Pairs, err = matcher.CheckPolicy(result.Pairs, opts.Policy, opts.MaxValidity)
+	if err != nil {
+		return result, err
+	}
+
+	result.Pairs, err = matcher.FilterSelfSigned(result.Pairs, opts.SelfSigned)
+	if err != nil {
+		return result, err
+	}
+
+	result.Pairs = matcher.Dedupe(result.Pairs)
+
+	result.Pairs, err = matcher.ResolveDuplicates(result.Pairs, opts.Prefer)
+	if err != nil {
+		return result, err
+	}
+
+	if opts.GenerateMissing != nil {
+		err := generateMissing(ctx, &opts, &result, write)
+		if err != nil {
+			return result, err
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return result, err
+	}
+
+	if opts.SplitCombinedDir != "" {
+		var files []scanner.ManagedFile
+
+		result.Pairs, files, err = matcher.SplitCombined(result.Pairs, opts.SplitCombinedDir)
+		if err != nil {
+			return result, err
+		}
+
+		result.managed = append(result.managed, files...)
+	}
+
+	if opts.Chain.CacheDir == "" {
+		opts.Chain.CacheDir = cacheDir("intermediates")
+	}
+
+	var chainFiles []scanner.ManagedFile
+
+	result.Pairs, chainFiles, err = matcher.CompleteChains(ctx, result.Pairs, result.Intermediates, &opts.Chain)
+	if err != nil {
+		return result, err
+	}
+
+	result.managed = append(result.managed, chainFiles...)
+
+	if opts.OCSPCacheDir == "" {
+		opts.OCSPCacheDir = cacheDir("ocsp")
+	}
+
+	ocspClient := revocation.NewOCSPClient(opts.OCSPCacheDir)
+
+	if opts.Revocation != nil {
+		err := checkRevocation(ctx, &result, opts.Revocation, ocspClient)
+		if err != nil {
+			return result, err
+		}
+	}
+
+	if opts.VerifyChain {
+		roots, err := loadRoots(opts.CABundle)
+		if err != nil {
+			return result, err
+		}
+
+		verifyChains(&result, roots, opts.ExcludeUnverifiable)
+	}
+
+	if write {
+		err = writeManaged(result.managed)
+		if err != nil {
+			return result, err
+		}
+
+		result.managed = nil
+	}
+
+	// The copies are checked by writePairFiles once they are written.
+	if opts.CopyTo == "" {
+		permissions := opts.Permissions
+		permissions.Fix = permissions.Fix && write
+
+		err = checkPermissions(result.Pairs, &permissions, result.unwritten())
+		if err != nil {
+			return result, err
+		}
+	}
+
+	if opts.CopyTo != "" {
+		result.Pairs, result.copies, err = planCopies(result.Pairs, opts.CopyTo, opts.CopyMode)
+		if err != nil {
+			return result, err
+		}
+	}
+
+	result.MissingDomains = missingDomains(result.Pairs, opts.RequireDomains)
+	result.ExpiryFailed = checkExpiry(result.Pairs, opts.WarnDays, opts.FailDays)
+	result.Duration = time.Since(start)
+
+	return result, nil
+}
+
+// Generate scans opts.Dirs like Scan and writes the config to opts.Out.
+// Nothing is written if the directory contains neither certificates nor keys
+// or opts.Check is set.
+func Generate(ctx context.Context, opts Options) (Result, error) {
+	start := time.Now()
+
+	if opts.Out == "" && opts.OutDir == "" && len(opts.Outputs) == 0 && !opts.DryRun && !opts.ReturnContent && opts.Push == nil && opts.KV == nil && opts.Kube == nil {
+		return Result{}, errors.New("output file must be set")
+	}
+
+	if opts.Format == "" {
+		opts.Format = "traefik"
+	}
+
+	setDirPrefixes(&opts)
+
+	renderer, err := render.New(opts.Format, &opts.Render)
+	if err != nil {
+		return Result{}, err
+	}
+
+	// The lock is held from reading the previous config until the write, so
+	// overlapping runs neither interleave writes nor undo each other.
+	release, err := lockOutputs(ctx, &opts)
+	if err != nil {
+		return Result{}, err
+	}
+
+	defer release()
+
+	result, err := scan(ctx, opts, !opts.Check && !opts.DryRun)
+	if err != nil {
+		return result, err
+	}
+
+	if result.Certs == 0 && result.Keys == 0 {
+		if opts.Check || opts.DryRun || opts.Out == "" {
+			return result, nil
+		}
+
+		// Nothing is written anyway, but an emptied directory is reported.
+		previous, _ := ioutil.ReadFile(opts.Out)
+
+		return result, checkShrink(previous, len(result.Pairs), &opts)
+	}
+
+	if opts.MTLS || opts.ClientCADir != "" {
+		caDir := filepath.Clean(opts.ClientCADir)
+
+		for _, caFile := range result.CAFiles {
+			if opts.ClientCADir != "" && !strings.HasPrefix(caFile, caDir+string(filepath.Separator)) {
+				continue
+			}
+
+			log.WithField("path", caFile).Info("Client CA")
+			opts.Render.CAFiles = append(opts.Render.CAFiles, caFile)
+		}
+	}
+
+	if opts.VerifyPaths {
+		verifyConfigPaths(result.Pairs, &opts.Render, opts.VerifyRoot)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return result, err
+	}
+
+	previous, content, err := renderConfigFile(result.Pairs, opts.Out, renderer, &opts)
+	if err != nil {
+		return result, err
+	}
+
+	result.Changed = previous == nil || !bytes.Equal(previous, content)
+
+	if result.Changed && opts.Out != "" && !opts.DryRun && !opts.NoValidate {
+		err = validateConfig(opts.Out, opts.Format, content, &result, &opts.Render)
+		if err != nil {
+			return result, err
+		}
+	}
+
+	if !opts.Check && !opts.DryRun {
+		err = checkShrink(previous, len(result.Pairs), &opts)
+		if err != nil {
+			return result, err
+		}
+
+		// Before any config is written, as all of them refer to the files.
+		err = writePairFiles(ctx, &opts, &result)
+		if err != nil {
+			return result, err
+		}
+	}
+
+	var extraChanged bool
+	var extraDiff string
+
+	if opts.OutDir != "" {
+		extraChanged, extraDiff, err = writeOutDir(result.Pairs, &opts)
+		if err != nil {
+			return result, err
+		}
+
+		if opts.Out == "" {
+			result.Changed = false
+		}
+	}
+
+	if len(opts.Outputs) > 0 {
+		outputsChanged, outputsDiff, err := writeOutputs(&result, &opts)
+		if err != nil {
+			return result, err
+		}
+
+		if opts.Out == "" {
+			result.Changed = false
+		}
+
+		extraChanged = extraChanged || outputsChanged
+		extraDiff += outputsDiff
+	}
+
+	if opts.Check || opts.DryRun {
+		if opts.Check && result.Changed {
+			result.Diff = Diff(opts.Out, opts.Out+" (generated)", previous, content)
+		}
+
+		result.Changed = result.Changed || extraChanged
+		result.Diff += extraDiff
+
+		if opts.DryRun {
+			result.Content = content
+		}
+
+		return result, nil
+	}
+
+	// Traefik keeps pushed configs in memory only, so they are sent every
+	// run to cover restarts. Traefik skips unchanged configs itself.
+	if opts.Push != nil {
+		restContent, err := render.TraefikJSON(result.Pairs, &opts.Render)
+		if err != nil {
+			return result, err
+		}
+
+		err = push(ctx, opts.Push, restContent)
+		if err != nil {
+			return result, err
+		}
+
+		result.Pushed = true
+	}
+
+	if opts.KV != nil {
+		err = writeKV(ctx, opts.KV, result.Pairs, &opts.Render)
+		if err != nil {
+			return result, err
+		}
+	}
+
+	if opts.Kube != nil {
+		err = applyKube(ctx, opts.Kube, result.Pairs, opts.Load.Passphrases)
+		if err != nil {
+			return result, err
+		}
+	}
+
+	if opts.Out == "" {
+		result.Changed = false
+	} else if !result.Changed {
+		log.WithField("path", opts.Out).Info("Config unchanged, skipping write")
+	} else {
+		if previous != nil {
+			result.Diff = Diff(opts.Out, opts.Out+" (generated)", previous, content)
+			logCertChanges(previous, content, result.Pairs, &opts.Render)
+
+			result.DroppedDomains = droppedDomains(previous, result.Pairs)
+			for _, domain := range result.DroppedDomains {
+				log.WithField("domain", domain).Warn("Domain dropped from config")
+			}
+		}
+
+		log.WithField("path", opts.Out).Info("Writing config")
+
+		err = writeConfig(opts.Out, content, opts.Backup)
+		if err != nil {
+			return result, err
+		}
+	}
+
+	// Only now no config refers to the stale files anymore.
+	err = removeStale(result.cleanups)
+	if err != nil {
+		return result, err
+	}
+
+	if opts.ReturnContent {
+		result.Content = content
+	}
+
+	result.Changed = result.Changed || extraChanged
+	result.Diff += extraDiff
+	result.Duration = time.Since(start)
+
+	return result, nil
+}
+
+// writePairFiles writes the copies planned by scan and the OCSP staples of
+// the pairs, which the config refers to, and checks or fixes the permissions
+// of the copies. The removal of the other files of opts.CopyTo is added to
+// the cleanups of result.
+func writePairFiles(ctx context.Context, opts *Options, result *Result) error {
+	if opts.CopyTo != "" {
+		c, err := copyPairs(result.copies, opts.CopyTo, opts.CopyMode)
+		if err != nil {
+			return err
+		}
+
+		result.cleanups = append(result.cleanups, c)
+
+		err = checkPermissions(result.Pairs, &opts.Permissions, nil)
+		if err != nil {
+			return err
+		}
+	}
+
+	if opts.OCSPStaple {
+		dir := opts.OCSPCacheDir
+		if dir == "" {
+			dir = cacheDir("ocsp")
+		}
+
+		return writeStaples(ctx, result, revocation.NewOCSPClient(dir))
+	}
+
+	return nil
+}
+
+// writeManaged writes the managed files planned by the scan.
+func writeManaged(files []scanner.ManagedFile) error {
+	for _, file := range files {
+		err := os.MkdirAll(filepath.Dir(file.Path), 0755)
+		if err != nil {
+			return err
+		}
+
+		err = writeFileIfChanged(file.Path, file.Content, file.Perm)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// setDirPrefixes passes the path prefixes of opts.Dirs to the renderer.
+func setDirPrefixes(opts *Options) {
+	for _, dir := range opts.Dirs {
+		if dir.PathPrefix == "" {
+			continue
+		}
+
+		if opts.Render.DirPrefixes == nil {
+			opts.Render.DirPrefixes = map[string]string{}
+		}
+
+		opts.Render.DirPrefixes[filepath.Join(dir.Path, ".")] = dir.PathPrefix
+	}
+}
+
+// KVOptions select the key-value store the config is written to.
+type KVOptions struct {
+	Store kv.Store
+	// Root is the root key of Traefik's KV provider, "traefik" if empty.
+	Root string
+	// Embed writes the content of the files instead of their paths.
+	Embed bool
+}
+
+// writeKV writes the pairs into the store in the key layout of Traefik's KV
+// providers.
+func writeKV(ctx context.Context, opts *KVOptions, pairs []matcher.KeyPair, renderOpts *render.Options) error {
+	root := opts.Root
+	if root == "" {
+		root = "traefik"
+	}
+
+	embedOpts := *renderOpts
+	embedOpts.EmbedFiles = opts.Embed
+
+	content, err := render.TraefikJSON(pairs, &embedOpts)
+	if err != nil {
+		return err
+	}
+
+	values, err := kv.Flatten(root, content)
+	if err != nil {
+		return err
+	}
+
+	log.WithFields(log.Fields{"root": root, "keys": len(values)}).Info("Writing config to kv store")
+
+	return kv.Write(ctx, opts.Store, root, values)
+}
+
+// sourceHash returns a hash of the paths and certificates of the pairs.
+func sourceHash(pairs []matcher.KeyPair) string {
+	hash := sha256.New()
+
+	for _, pair := range pairs {
+		hash.Write([]byte(pair.CertPath + "\x00" + pair.KeyPath + "\x00"))
+		hash.Write(pair.Cert.Raw)
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)[:8])
+}
+
+// finishBlock applies opts.Stamp and opts.NoHeader to the rendered block of
+// the pairs.
+func finishBlock(block []byte, pairs []matcher.KeyPair, opts *Options) []byte {
+	if opts.Stamp != nil {
+		meta := render.Metadata{Version: opts.Stamp.Version, SourceHash: sourceHash(pairs), Pairs: len(pairs)}
+		if !opts.Stamp.OmitTime {
+			meta.Time = time.Now()
+		}
+
+		block = render.Stamp(block, meta)
+	}
+
+	if opts.NoHeader {
+		block = render.StripMarkers(block)
+	}
+
+	return block
+}
+
+// renderConfigFile renders the pairs and merges them into the current
+// content of outFile. previous is nil if the file does not exist yet or
+// outFile is empty.
+func renderConfigFile(pairs []matcher.KeyPair, outFile string, renderer render.Renderer, opts *Options) ([]byte, []byte, error) {
+	log.WithField("pairs", len(pairs)).Info("Found valid keypairs")
+
+	block, err := renderer.Render(pairs)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	block = finishBlock(block, pairs, opts)
+
+	if outFile == "" {
+		return nil, render.Merge(nil, block), nil
+	}
+
+	previous, err := ioutil.ReadFile(outFile)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, nil, err
+	}
+
+	return previous, render.Merge(previous, block), nil
+}
+
+// writeFileAtomic writes data to a temporary file next to path and renames it
+// into place, so readers never observe a partially written file.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Sync()
+	}
+
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		return err
+	}
+
+	err = os.Chmod(tmp.Name(), perm)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// checkExpiry logs pairs whose certificate expires within the given number of
+// days and reports whether any of them expires within failDays. Thresholds of
+// zero are disabled.
+func checkExpiry(pairs []matcher.KeyPair, warnDays int, failDays int) bool {
+	failed := false
+
+	for _, pair := range pairs {
+		remaining := time.Until(pair.Cert.NotAfter)
+		days := int(remaining.Hours() / 24)
+
+		if failDays > 0 && remaining < time.Duration(failDays)*24*time.Hour {
+			scanner.CertLogger(pair.CertPath, pair.Cert).WithField("days", days).Error("Certificate expires soon")
+			failed = true
+		} else if warnDays > 0 && remaining < time.Duration(warnDays)*24*time.Hour {
+			scanner.CertLogger(pair.CertPath, pair.Cert).WithField("days", days).Warn("Certificate expires soon")
+		}
+	}
+
+	return failed
+}
@@ -0,0 +1,102 @@
+package tlsconfig
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writePEM writes a single PEM block to path, creating its directory.
+func writePEM(t *testing.T, path, blockType string, der []byte) {
+	err := os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = ioutil.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// newPair returns a self-signed certificate for domain valid until notAfter
+// and its private key, both DER encoded.
+func newPair(t *testing.T, domain string, notAfter time.Time) ([]byte, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: domain},
+		DNSNames:     []string{domain},
+		NotBefore:    notAfter.Add(-24 * time.Hour),
+		NotAfter:     notAfter,
+	}
+
+	cert, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return cert, keyDER
+}
+
+func TestScanKeyCopyIsNotOrphan(t *testing.T) {
+	dir := t.TempDir()
+	cert, keyDER := newPair(t, "example.com", time.Now().Add(time.Hour))
+
+	writePEM(t, filepath.Join(dir, "example.com", "cert.pem"), "CERTIFICATE", cert)
+	writePEM(t, filepath.Join(dir, "example.com", "privkey.pem"), "EC PRIVATE KEY", keyDER)
+	writePEM(t, filepath.Join(dir, "backup", "privkey.pem"), "EC PRIVATE KEY", keyDER)
+
+	result, err := Scan(context.Background(), Options{Dirs: []Dir{{Path: dir}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(result.Pairs) != 1 {
+		t.Fatalf("got %d pairs, want 1", len(result.Pairs))
+	}
+
+	if result.OrphanKeys != 0 || len(result.Orphans) != 0 {
+		t.Errorf("got orphans %v, want none", result.Orphans)
+	}
+}
+
+func TestScanExpiredIsNotScanError(t *testing.T) {
+	dir := t.TempDir()
+	cert, keyDER := newPair(t, "example.com", time.Now().Add(-time.Hour))
+
+	writePEM(t, filepath.Join(dir, "example.com", "cert.pem"), "CERTIFICATE", cert)
+	writePEM(t, filepath.Join(dir, "example.com", "privkey.pem"), "EC PRIVATE KEY", keyDER)
+
+	result, err := Scan(context.Background(), Options{Dirs: []Dir{{Path: dir}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.ScanErrors != 0 || result.Expired != 1 {
+		t.Errorf("got %d scan errors and %d expired, want 0 and 1", result.ScanErrors, result.Expired)
+	}
+
+	if len(result.Orphans) != 0 {
+		t.Errorf("got orphans %v, want none", result.Orphans)
+	}
+}
@@ -0,0 +1,281 @@
+package tlsconfig
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/chrisxf/traefik-tls-config-gen/matcher"
+	"github.com/chrisxf/traefik-tls-config-gen/render"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v2"
+)
+
+type dynamicCertificate struct {
+	CertFile string   `toml:"certFile" yaml:"certFile" json:"certFile"`
+	KeyFile  string   `toml:"keyFile" yaml:"keyFile" json:"keyFile"`
+	Stores   []string `toml:"stores" yaml:"stores" json:"stores"`
+}
+
+type dynamicStore struct {
+	DefaultCertificate *dynamicCertificate `toml:"defaultCertificate" yaml:"defaultCertificate" json:"defaultCertificate"`
+}
+
+type dynamicClientAuth struct {
+	CAFiles []string `toml:"caFiles" yaml:"caFiles" json:"caFiles"`
+}
+
+type dynamicOptions struct {
+	ClientAuth *dynamicClientAuth `toml:"clientAuth" yaml:"clientAuth" json:"clientAuth"`
+}
+
+// dynamicConfig is the part of the dynamic config of Traefik v2 and later
+// that is validated, everything else in the file is ignored.
+type dynamicConfig struct {
+	TLS struct {
+		Certificates []dynamicCertificate      `toml:"certificates" yaml:"certificates" json:"certificates"`
+		Stores       map[string]dynamicStore   `toml:"stores" yaml:"stores" json:"stores"`
+		Options      map[string]dynamicOptions `toml:"options" yaml:"options" json:"options"`
+	} `toml:"tls" yaml:"tls" json:"tls"`
+}
+
+// legacyConfig is the TLS part of the Traefik v1 file provider config.
+type legacyConfig struct {
+	TLS []struct {
+		EntryPoints []string            `toml:"entryPoints" yaml:"entryPoints"`
+		Certificate *dynamicCertificate `toml:"certificate" yaml:"certificate"`
+	} `toml:"tls" yaml:"tls"`
+}
+
+// configSyntax returns the syntax Traefik reads a config of format at path
+// with, or "" for formats that are no Traefik config.
+func configSyntax(path string, format string) string {
+	switch format {
+	case "traefik":
+		return "toml"
+	case "json", "traefik-json":
+		return "json"
+	case "template":
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".toml":
+			return "toml"
+		case ".yaml", ".yml":
+			return "yaml"
+		case ".json":
+			return "json"
+		}
+	}
+
+	return ""
+}
+
+func decodeConfig(syntax string, content []byte, config interface{}) error {
+	switch syntax {
+	case "toml":
+		_, err := toml.Decode(string(content), config)
+		return err
+	case "yaml":
+		return yaml.Unmarshal(content, config)
+	default:
+		return json.Unmarshal(content, config)
+	}
+}
+
+// configValidator collects the problems of a config.
+type configValidator struct {
+	// files maps the paths written into the config to the scanned files.
+	files map[string]string
+	// unwritten are the files written only after the config is validated.
+	unwritten map[string]bool
+	problems  []string
+}
+
+func newConfigValidator(result *Result, opts *render.Options) *configValidator {
+	v := &configValidator{files: map[string]string{}, unwritten: result.unwritten()}
+
+	// Copies not written yet are checked by the files they are made from.
+	sources := result.sourceFiles()
+	source := func(path string) string {
+		if file, ok := sources[path]; ok {
+			return file
+		}
+
+		return path
+	}
+
+	for _, pair := range result.Pairs {
+		v.files[opts.ConfigPath(pair.CertPath)] = source(pair.CertPath)
+		v.files[opts.ConfigPath(pair.KeyPath)] = source(pair.KeyPath)
+	}
+
+	for _, caFile := range opts.CAFiles {
+		v.files[opts.ConfigPath(caFile)] = caFile
+	}
+
+	return v
+}
+
+func (v *configValidator) fail(problem string) {
+	v.problems = append(v.problems, problem)
+}
+
+// checkFile reports empty paths and generated entries whose files do not
+// exist and are not about to be written. Paths outside of the generated
+// entries may only exist for Traefik, e.g. in its container, and are not
+// checked.
+func (v *configValidator) checkFile(what string, path string) {
+	if path == "" {
+		v.fail("empty " + what)
+		return
+	}
+
+	file, ok := v.files[path]
+	if !ok || v.unwritten[file] {
+		return
+	}
+
+	if _, err := os.Stat(file); err != nil {
+		v.fail(what + " " + path + " does not exist")
+	}
+}
+
+func (v *configValidator) checkCertificate(what string, cert *dynamicCertificate) {
+	v.checkFile(what+" certFile", cert.CertFile)
+	v.checkFile(what+" keyFile", cert.KeyFile)
+}
+
+func (v *configValidator) checkDynamic(config *dynamicConfig) {
+	seen := map[string]bool{}
+
+	for i := range config.TLS.Certificates {
+		cert := &config.TLS.Certificates[i]
+		v.checkCertificate("certificate", cert)
+
+		if cert.CertFile != "" && seen[cert.CertFile] {
+			v.fail("duplicate certFile " + cert.CertFile)
+		}
+
+		seen[cert.CertFile] = true
+
+		for _, store := range cert.Stores {
+			if store == "" {
+				v.fail("empty store name for certFile " + cert.CertFile)
+			}
+		}
+	}
+
+	for name, store := range config.TLS.Stores {
+		if store.DefaultCertificate != nil {
+			v.checkCertificate("default certificate of store "+name, store.DefaultCertificate)
+		}
+	}
+
+	for name, options := range config.TLS.Options {
+		if options.ClientAuth == nil {
+			continue
+		}
+
+		for _, caFile := range options.ClientAuth.CAFiles {
+			v.checkFile("caFile of TLS options "+name, caFile)
+		}
+	}
+}
+
+func (v *configValidator) checkLegacy(config *legacyConfig) {
+	seen := map[string]bool{}
+
+	for _, tls := range config.TLS {
+		for _, entryPoint := range tls.EntryPoints {
+			if entryPoint == "" {
+				v.fail("empty entry point name")
+			}
+		}
+
+		if tls.Certificate == nil {
+			v.fail("TLS entry without certificate")
+			continue
+		}
+
+		v.checkCertificate("certificate", tls.Certificate)
+
+		if tls.Certificate.CertFile != "" && seen[tls.Certificate.CertFile] {
+			v.fail("duplicate certFile " + tls.Certificate.CertFile)
+		}
+
+		seen[tls.Certificate.CertFile] = true
+	}
+}
+
+// validateConfig parses the content of the config at path back and checks
+// what Traefik would report as provider error: unreadable syntax, missing
+// files of the generated entries, empty entry point names and duplicate
+// certFile entries. Formats that are no Traefik config are not checked.
+func validateConfig(path string, format string, content []byte, result *Result, opts *render.Options) error {
+	syntax := configSyntax(path, format)
+	if syntax == "" {
+		return nil
+	}
+
+	v := newConfigValidator(result, opts)
+
+	var err error
+	if opts.TraefikVersion >= 2 || syntax == "json" {
+		config := &dynamicConfig{}
+		if err = decodeConfig(syntax, content, config); err == nil {
+			v.checkDynamic(config)
+		}
+	} else {
+		config := &legacyConfig{}
+		if err = decodeConfig(syntax, content, config); err == nil {
+			v.checkLegacy(config)
+		}
+	}
+
+	if err != nil {
+		return errors.New("invalid config " + path + ": " + err.Error())
+	}
+
+	if len(v.problems) > 0 {
+		return errors.New("invalid config " + path + ": " + strings.Join(v.problems, "; "))
+	}
+
+	return nil
+}
+
+// verifyConfigPaths warns about the files written into the config, after
+// prefixing and path maps, that do not exist below root, which is where
+// Traefik's file system is visible. Relative paths are looked up below
+// root or else below opts.RelativeTo.
+func verifyConfigPaths(pairs []matcher.KeyPair, opts *render.Options, root string) {
+	var files []string
+	for _, pair := range pairs {
+		files = append(files, pair.CertPath, pair.KeyPath)
+	}
+
+	files = append(files, opts.CAFiles...)
+
+	seen := map[string]bool{}
+
+	for _, file := range files {
+		path := opts.ConfigPath(file)
+		if seen[path] || strings.HasPrefix(path, "-----BEGIN") {
+			continue
+		}
+
+		seen[path] = true
+
+		local := filepath.FromSlash(path)
+		if root != "" {
+			local = filepath.Join(root, local)
+		} else if !filepath.IsAbs(local) && opts.RelativeTo != "" {
+			local = filepath.Join(opts.RelativeTo, local)
+		}
+
+		if _, err := os.Stat(local); err != nil {
+			log.WithFields(log.Fields{"path": path, "file": file, "checked": local}).Warn("File referenced by config does not exist")
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package tlsconfig
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"errors"
+	"io/ioutil"
+
+	"github.com/chrisxf/traefik-tls-config-gen/matcher"
+	"github.com/chrisxf/traefik-tls-config-gen/scanner"
+)
+
+// loadRoots returns the certificates of bundle, the system roots if bundle
+// is empty.
+func loadRoots(bundle string) (*x509.CertPool, error) {
+	if bundle == "" {
+		roots, err := x509.SystemCertPool()
+		if err != nil {
+			roots = x509.NewCertPool()
+		}
+
+		return roots, nil
+	}
+
+	content, err := ioutil.ReadFile(bundle)
+	if err != nil {
+		return nil, err
+	}
+
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(content) {
+		return nil, errors.New("no certificates found in " + bundle)
+	}
+
+	return roots, nil
+}
+
+// verifyChain verifies the chain of pair against roots, using the
+// intermediates found in the directories for missing chain certificates.
+func verifyChain(pair matcher.KeyPair, roots *x509.CertPool, found *x509.CertPool) error {
+	intermediates := found.Clone()
+	for _, cert := range pair.Chain {
+		intermediates.AddCert(cert)
+	}
+
+	_, err := pair.Cert.Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+
+	return err
+}
+
+// verifyChains logs the pairs whose chain does not verify against roots and
+// drops them if exclude is set.
+func verifyChains(result *Result, roots *x509.CertPool, exclude bool) {
+	found := x509.NewCertPool()
+	for _, cert := range result.Intermediates {
+		if !bytes.Equal(cert.RawIssuer, cert.RawSubject) {
+			found.AddCert(cert)
+		}
+	}
+
+	var pairs []matcher.KeyPair
+
+	for _, pair := range result.Pairs {
+		err := verifyChain(pair, roots, found)
+		if err != nil {
+			logger := scanner.CertLogger(pair.CertPath, pair.Cert).WithError(err)
+			result.Invalid++
+
+			if exclude {
+				logger.Error("Chain verification failed, skipping pair")
+				continue
+			}
+
+			logger.Warn("Chain verification failed, clients will reject the certificate")
+		}
+
+		pairs = append(pairs, pair)
+	}
+
+	result.Pairs = pairs
+}
+
+// Verify scans opts.Dirs like Scan and checks that the chain of every pair
+// verifies against the system roots, or opts.CABundle, or a self-signed CA
+// found in the directory. Nothing is written.
+func Verify(ctx context.Context, opts Options) (Result, error) {
+	opts.VerifyChain = false
+
+	result, err := Scan(ctx, opts)
+	if err != nil {
+		return result, err
+	}
+
+	roots, err := loadRoots(opts.CABundle)
+	if err != nil {
+		return result, err
+	}
+
+	found := x509.NewCertPool()
+
+	for _, cert := range result.Intermediates {
+		if bytes.Equal(cert.RawIssuer, cert.RawSubject) {
+			roots.AddCert(cert)
+		} else {
+			found.AddCert(cert)
+		}
+	}
+
+	for _, pair := range result.Pairs {
+		err := verifyChain(pair, roots, found)
+		if err != nil {
+			scanner.CertLogger(pair.CertPath, pair.Cert).WithError(err).Error("Chain verification failed")
+			result.Invalid++
+			continue
+		}
+
+		scanner.CertLogger(pair.CertPath, pair.Cert).Info("Chain verified")
+	}
+
+	return result, nil
+}
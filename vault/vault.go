@@ -0,0 +1,308 @@
+// Package vault reads certificate and key pairs stored in the KV secrets
+// engine of HashiCorp Vault.
+package vault
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const kubernetesTokenFile = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+
+// Options configure the connection to Vault and the authentication.
+type Options struct {
+	// Addr defaults to $VAULT_ADDR.
+	Addr string
+	// CAFile verifies the Vault server certificate.
+	CAFile string
+	// Namespace is the Vault Enterprise namespace.
+	Namespace string
+
+	// Auth is "token", "approle" or "kubernetes". AuthMount defaults to
+	// the name of the method.
+	Auth      string
+	AuthMount string
+	// Token defaults to $VAULT_TOKEN.
+	Token    string
+	RoleID   string
+	SecretID string
+	// Role is the role of the Kubernetes auth method, JWTFile the service
+	// account token, the one of the pod if empty.
+	Role    string
+	JWTFile string
+}
+
+// Client is an authenticated Vault client.
+type Client struct {
+	addr      string
+	namespace string
+	token     string
+	http      *http.Client
+}
+
+// Error is a non-success response of Vault.
+type Error struct {
+	Status string   `json:"-"`
+	Code   int      `json:"-"`
+	Errors []string `json:"errors"`
+}
+
+func (e *Error) Error() string {
+	return "vault: " + e.Status + ": " + strings.Join(e.Errors, ", ")
+}
+
+// Pair is a certificate and key read from Vault.
+type Pair struct {
+	// Path is the secret path below the mount.
+	Path string
+	Cert []byte
+	Key  []byte
+}
+
+// NewClient authenticates with the method of opts.
+func NewClient(ctx context.Context, opts *Options) (*Client, error) {
+	addr := opts.Addr
+	if addr == "" {
+		addr = os.Getenv("VAULT_ADDR")
+	}
+
+	if addr == "" {
+		return nil, errors.New("vault address must be set")
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if opts.CAFile != "" {
+		ca, err := ioutil.ReadFile(opts.CAFile)
+		if err != nil {
+			return nil, err
+		}
+
+		roots := x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(ca) {
+			return nil, errors.New("no certificates found in " + opts.CAFile)
+		}
+
+		transport.TLSClientConfig = &tls.Config{RootCAs: roots}
+	}
+
+	c := &Client{
+		addr:      strings.TrimSuffix(addr, "/"),
+		namespace: opts.Namespace,
+		http:      &http.Client{Transport: transport, Timeout: 30 * time.Second},
+	}
+
+	mount := opts.AuthMount
+	if mount == "" {
+		mount = opts.Auth
+	}
+
+	switch opts.Auth {
+	case "", "token":
+		c.token = opts.Token
+		if c.token == "" {
+			c.token = os.Getenv("VAULT_TOKEN")
+		}
+
+		if c.token == "" {
+			return nil, errors.New("vault token must be set")
+		}
+
+		return c, nil
+	case "approle":
+		return c, c.login(ctx, mount, map[string]string{"role_id": opts.RoleID, "secret_id": opts.SecretID})
+	case "kubernetes":
+		jwtFile := opts.JWTFile
+		if jwtFile == "" {
+			jwtFile = kubernetesTokenFile
+		}
+
+		jwt, err := ioutil.ReadFile(jwtFile)
+		if err != nil {
+			return nil, err
+		}
+
+		return c, c.login(ctx, mount, map[string]string{"role": opts.Role, "jwt": strings.TrimSpace(string(jwt))})
+	default:
+		return nil, errors.New("unknown vault auth method " + opts.Auth)
+	}
+}
+
+// login fetches a client token from the auth method mounted at mount.
+func (c *Client) login(ctx context.Context, mount string, request map[string]string) error {
+	var response struct {
+		Auth struct {
+			ClientToken string `json:"client_token"`
+		} `json:"auth"`
+	}
+
+	err := c.do(ctx, http.MethodPost, "auth/"+mount+"/login", request, &response)
+	if err != nil {
+		return err
+	}
+
+	c.token = response.Auth.ClientToken
+
+	log.WithField("mount", mount).Debug("Logged in to vault")
+
+	return nil
+}
+
+func (c *Client) do(ctx context.Context, method string, path string, request interface{}, response interface{}) error {
+	var body io.Reader
+
+	if request != nil {
+		content, err := json.Marshal(request)
+		if err != nil {
+			return err
+		}
+
+		body = bytes.NewReader(content)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.addr+"/v1/"+strings.TrimPrefix(path, "/"), body)
+	if err != nil {
+		return err
+	}
+
+	if c.token != "" {
+		req.Header.Set("X-Vault-Token", c.token)
+	}
+
+	if c.namespace != "" {
+		req.Header.Set("X-Vault-Namespace", c.namespace)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	content, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode/100 != 2 {
+		vaultErr := &Error{Status: resp.Status, Code: resp.StatusCode}
+		json.Unmarshal(content, vaultErr)
+
+		return vaultErr
+	}
+
+	if response == nil {
+		return nil
+	}
+
+	return json.Unmarshal(content, response)
+}
+
+// list returns the keys below path, sub paths ending with a slash. A missing
+// path yields no keys.
+func (c *Client) list(ctx context.Context, path string) ([]string, error) {
+	var response struct {
+		Data struct {
+			Keys []string `json:"keys"`
+		} `json:"data"`
+	}
+
+	err := c.do(ctx, "LIST", path, nil, &response)
+
+	var vaultErr *Error
+	if errors.As(err, &vaultErr) && vaultErr.Code == http.StatusNotFound {
+		return nil, nil
+	}
+
+	return response.Data.Keys, err
+}
+
+// field returns the first of the fields set in data.
+func field(data map[string]interface{}, names ...string) string {
+	for _, name := range names {
+		if value, ok := data[name].(string); ok && value != "" {
+			return value
+		}
+	}
+
+	return ""
+}
+
+// ReadPairs reads all secrets below path of the KV engine mounted at mount
+// that hold a certificate and a private key. Version is the KV engine
+// version, 1 or 2.
+func (c *Client) ReadPairs(ctx context.Context, mount string, path string, version int) ([]Pair, error) {
+	mount = strings.Trim(mount, "/")
+	path = strings.Trim(path, "/")
+
+	listPrefix, readPrefix := mount+"/", mount+"/"
+	if version != 1 {
+		listPrefix, readPrefix = mount+"/metadata/", mount+"/data/"
+	}
+
+	var pairs []Pair
+
+	pending := []string{path}
+
+	for len(pending) > 0 {
+		dir := pending[0]
+		pending = pending[1:]
+
+		keys, err := c.list(ctx, listPrefix+dir)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, key := range keys {
+			secretPath := strings.TrimPrefix(dir+"/"+key, "/")
+
+			if strings.HasSuffix(key, "/") {
+				pending = append(pending, strings.TrimSuffix(secretPath, "/"))
+				continue
+			}
+
+			var response struct {
+				Data map[string]interface{} `json:"data"`
+			}
+
+			err := c.do(ctx, http.MethodGet, readPrefix+secretPath, nil, &response)
+			if err != nil {
+				return nil, err
+			}
+
+			data := response.Data
+			if version != 1 {
+				data, _ = data["data"].(map[string]interface{})
+			}
+
+			certPEM := field(data, "certificate", "cert", "tls.crt", "crt")
+			keyPEM := field(data, "private_key", "key", "tls.key")
+
+			if certPEM == "" || keyPEM == "" {
+				log.WithField("path", secretPath).Debug("Vault secret without certificate and key")
+				continue
+			}
+
+			if chain := field(data, "ca_chain", "chain", "issuing_ca"); chain != "" {
+				certPEM = strings.TrimRight(certPEM, "\n") + "\n" + chain
+			}
+
+			pairs = append(pairs, Pair{Path: secretPath, Cert: []byte(certPEM), Key: []byte(keyPEM)})
+		}
+	}
+
+	return pairs, nil
+}